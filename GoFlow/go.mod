@@ -2,3 +2,4 @@ module goflow
 
 go 1.15
 
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,177 @@
+package goflow
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// ICacheKeyFunc returns the key of the cached outcome of a node, an empty key skips the cache for this run
+type ICacheKeyFunc = func(_data *_Data) string
+
+// ICacheSaveFunc takes the effect of a node on the data after it ran, which is kept in the cache
+type ICacheSaveFunc = func(_data *_Data) interface{}
+
+// ICacheApplyFunc puts the effect kept by ICacheSaveFunc back into the data instead of running the node
+type ICacheApplyFunc = func(_data *_Data, value interface{})
+
+// ICache keeps the outcomes of the cached nodes. The context is the one of the flow, which RequestCache uses to
+// find the cache of the request.
+type ICache interface {
+	Get(ctx context.Context, key string) (interface{}, bool)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration)
+}
+
+// NodeCache is set on a node by SetCache and SetCacheApply
+type NodeCache struct {
+	Key   ICacheKeyFunc
+	Cache ICache
+	TTL   time.Duration
+	Save  ICacheSaveFunc
+	Apply ICacheApplyFunc
+}
+
+// LRUCache keeps up to Capacity values and drops the least recently used one first. A value expires after its ttl
+// according to Clock, or never if the ttl is 0.
+type LRUCache struct {
+	Capacity int
+	Clock    Clock
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{Capacity: capacity, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (l *LRUCache) SetClock(clock Clock) *LRUCache {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.Clock = clock
+	return l
+}
+
+func (l *LRUCache) Get(ctx context.Context, key string) (interface{}, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && clockOr(l.Clock).Now().After(entry.expiresAt) {
+		l.order.Remove(element)
+		delete(l.entries, key)
+		return nil, false
+	}
+	l.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (l *LRUCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	entry := &lruEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expiresAt = clockOr(l.Clock).Now().Add(ttl)
+	}
+	if element, ok := l.entries[key]; ok {
+		element.Value = entry
+		l.order.MoveToFront(element)
+		return
+	}
+	l.entries[key] = l.order.PushFront(entry)
+	for l.Capacity > 0 && l.order.Len() > l.Capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *LRUCache) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.order.Len()
+}
+
+type requestCacheKey struct{}
+
+// WithRequestCache returns a context carrying a new cache for one request. The nodes using RequestCache keep their
+// outcomes in it, so they are shared by all the flows and sub-paths of the request and dropped with it.
+func WithRequestCache(ctx context.Context, capacity int) context.Context {
+	return context.WithValue(ctx, requestCacheKey{}, NewLRUCache(capacity))
+}
+
+type requestCache struct{}
+
+// RequestCache is the cache of the request found in the context of the flow, see WithRequestCache. Nothing is cached
+// if the context has no cache. A cache created by NewLRUCache and shared by the flows lives as long as the process.
+var RequestCache ICache = requestCache{}
+
+func (requestCache) Get(ctx context.Context, key string) (interface{}, bool) {
+	if cache, ok := ctx.Value(requestCacheKey{}).(*LRUCache); ok {
+		return cache.Get(ctx, key)
+	}
+	return nil, false
+}
+
+func (requestCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	if cache, ok := ctx.Value(requestCacheKey{}).(*LRUCache); ok {
+		cache.Set(ctx, key, value, ttl)
+	}
+}
+
+// cacheable tells whether the cache of a node is used, the nodes of If chains, Finally and Catch decide more than
+// their own outcome and are always run
+func cacheable(node IBasicFlowNode) bool {
+	switch node.(type) {
+	case *NormalNode, *ForNode, *ParallelNode, *PrepareNode:
+		return true
+	}
+	return false
+}
+
+// runNode runs a node, or puts its cached effect back into the data and marks it with CachedNodeState
+func runNode(node IBasicFlowNode, data *_Data, result *_Result) {
+	cache := node.GetCache()
+	if cache == nil || cache.Cache == nil || cache.Key == nil || !cacheable(node) || node.GetShouldSkip() ||
+		result.Err != nil || result.StatusCode != 0 {
+		node.Run()
+		return
+	}
+	key := cache.Key(data)
+	if key == "" {
+		node.Run()
+		return
+	}
+	ctx := GetContext(data)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if value, ok := cache.Cache.Get(ctx, key); ok {
+		notify(&Event{Kind: CacheHitEvent, Note: node.GetNote(), Key: key})
+		if cache.Apply != nil {
+			cache.Apply(data, value)
+		}
+		node.SetState(CachedNodeState)
+		return
+	}
+	notify(&Event{Kind: CacheMissEvent, Note: node.GetNote(), Key: key})
+	node.Run()
+	if node.GetState() == SucceededNodeState {
+		var value interface{}
+		if cache.Save != nil {
+			value = cache.Save(data)
+		}
+		cache.Cache.Set(ctx, key, value, cache.TTL)
+	}
+}
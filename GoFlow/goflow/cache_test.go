@@ -0,0 +1,114 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUCacheDropsTheLeastRecentlyUsed(t *testing.T) {
+	cache := NewLRUCache(2)
+	ctx := context.Background()
+	cache.Set(ctx, "first", 1, 0)
+	cache.Set(ctx, "second", 2, 0)
+	cache.Get(ctx, "first")
+	cache.Set(ctx, "third", 3, 0)
+	if _, ok := cache.Get(ctx, "second"); ok {
+		t.Error("expected the least recently used value to be dropped")
+	}
+	if value, ok := cache.Get(ctx, "first"); !ok || value != 1 {
+		t.Errorf("expected the recently used value to be kept, got %v", value)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected the cache to keep its capacity, got %d", cache.Len())
+	}
+}
+
+func TestLRUCacheExpires(t *testing.T) {
+	clock := newTestClock()
+	cache := NewLRUCache(0).SetClock(clock)
+	ctx := context.Background()
+	cache.Set(ctx, "short", 1, time.Minute)
+	cache.Set(ctx, "forever", 2, 0)
+	clock.advance(time.Minute + time.Second)
+	if _, ok := cache.Get(ctx, "short"); ok {
+		t.Error("expected the value to expire after its ttl")
+	}
+	if _, ok := cache.Get(ctx, "forever"); !ok {
+		t.Error("expected the value without ttl to be kept")
+	}
+}
+
+func cachedFlow(cache ICache, calls *int) *FlowEngine {
+	return NewFlow().Do(func(_data *_Data) *_Result {
+		*calls++
+		_data.FunctionName = "computed"
+		return nil
+	}).SetCache(func(_data *_Data) string {
+		return "key"
+	}, cache, 0).SetCacheApply(func(_data *_Data) interface{} {
+		return _data.FunctionName
+	}, func(_data *_Data, value interface{}) {
+		_data.FunctionName = value.(string)
+	})
+}
+
+func TestCacheSkipsTheNode(t *testing.T) {
+	var hits, misses int
+	observer := func(event *Event) {
+		switch event.Kind {
+		case CacheHitEvent:
+			hits++
+		case CacheMissEvent:
+			misses++
+		}
+	}
+	defer AddObserver(observer)()
+
+	cache, calls := NewLRUCache(10), 0
+	cachedFlow(cache, &calls).Wait()
+	flow := cachedFlow(cache, &calls)
+	if result := flow.Wait(); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if calls != 1 || flow.getData().FunctionName != "computed" {
+		t.Errorf("expected the cached effect to be applied instead of running, got %d calls", calls)
+	}
+	if state := flow.nodes[0].GetState(); state != CachedNodeState {
+		t.Errorf("expected the node to be marked as cached, got %s", state)
+	}
+	if hits != 1 || misses != 1 {
+		t.Errorf("expected one hit and one miss, got %d and %d", hits, misses)
+	}
+}
+
+func TestRequestCacheLivesWithTheContext(t *testing.T) {
+	calls := 0
+	ctx := WithRequestCache(context.Background(), 10)
+	for index := 0; index < 2; index++ {
+		flow := cachedFlow(RequestCache, &calls)
+		SetContext(flow.getData(), ctx)
+		flow.Wait()
+	}
+	if calls != 1 {
+		t.Errorf("expected the flows of the request to share the cache, got %d calls", calls)
+	}
+	cachedFlow(RequestCache, &calls).Wait()
+	if calls != 2 {
+		t.Errorf("expected nothing to be cached without a request cache, got %d calls", calls)
+	}
+}
+
+func TestCacheIgnoresFailures(t *testing.T) {
+	cache, calls := NewLRUCache(10), 0
+	NewFlow().Do(fail).SetCache(func(_data *_Data) string {
+		return "key"
+	}, cache, 0).Wait()
+	if cache.Len() != 0 {
+		t.Error("expected a failed node not to be cached")
+	}
+	cachedFlow(cache, &calls).Wait()
+	if calls != 1 {
+		t.Errorf("expected the node to run, got %d calls", calls)
+	}
+}
@@ -0,0 +1,71 @@
+package goflow
+
+import (
+	"errors"
+	"reflect"
+)
+
+// scopeCatch links a new Catch to the node right before it. A Catch following a TrySubPath, or a Catch scoped to one,
+// only handles the failures of that TrySubPath.
+func scopeCatch(node *CatchNode, last IBasicFlowNode) {
+	switch l := last.(type) {
+	case *TrySubPathNode:
+		node.Try = l
+	case *CatchNode:
+		node.Try, node.Previous = l.Try, l
+	}
+}
+
+// MatchStatusCode selects the failures with one of the status codes
+func MatchStatusCode(codes ...int64) ICatchMatcher {
+	return func(_result *_Result) bool {
+		for _, code := range codes {
+			if _result.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchStatusRange selects the failures whose status code is between lo and hi, both included
+func MatchStatusRange(lo int64, hi int64) ICatchMatcher {
+	return func(_result *_Result) bool {
+		return _result.StatusCode >= lo && _result.StatusCode <= hi
+	}
+}
+
+// MatchError selects the failures whose error is target according to errors.Is
+func MatchError(target error) ICatchMatcher {
+	return func(_result *_Result) bool {
+		return _result.Err != nil && errors.Is(_result.Err, target)
+	}
+}
+
+// MatchErrorType selects the failures whose error, or any error it wraps, has the same type as target, e.g.
+// MatchErrorType(&PanicHappened{})
+func MatchErrorType(target error) ICatchMatcher {
+	targetType := reflect.TypeOf(target)
+	return func(_result *_Result) bool {
+		if _result.Err == nil || targetType == nil {
+			return false
+		}
+		return errors.As(_result.Err, reflect.New(targetType).Interface())
+	}
+}
+
+// failHandler is a handler registered by OnStatus, OnStatusRange or OnError
+type failHandler struct {
+	match   ICatchMatcher
+	handler IOnFailFunc
+}
+
+// failHandlerFor returns the first handler matching the failure, or onFail if none of them does
+func failHandlerFor(handlers []*failHandler, onFail IOnFailFunc, result *_Result) IOnFailFunc {
+	for _, handler := range handlers {
+		if handler.match(result) {
+			return handler.handler
+		}
+	}
+	return onFail
+}
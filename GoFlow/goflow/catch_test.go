@@ -0,0 +1,78 @@
+package goflow
+
+import (
+	"fmt"
+	"testing"
+)
+
+// markCatch is mark for a Catch handler
+func markCatch(name string) ICatchFunc {
+	return func(_data *_Data, _result *_Result) *_Result {
+		return mark(name)(_data)
+	}
+}
+
+func TestCatchClearsMatchingFailure(t *testing.T) {
+	var caught *_Result
+	flow := NewFlow().Do(func(_data *_Data) *_Result {
+		return &_Result{StatusCode: 404}
+	}).Do(mark("skipped")).
+		Catch(MatchStatusCode(500), markCatch("server")).
+		Catch(MatchStatusRange(400, 499), func(_data *_Data, _result *_Result) *_Result {
+			caught = _result
+			return nil
+		}).
+		Catch(nil, markCatch("second")).
+		Do(mark("after"))
+
+	if result := flow.Wait(); result.Err != nil || result.StatusCode != 0 {
+		t.Fatalf("expected the failure to be cleared, got %+v", result)
+	}
+	if caught == nil || caught.StatusCode != 404 {
+		t.Errorf("expected the handler to get the failure, got %+v", caught)
+	}
+	if data := flow.getData().FunctionName; data != "after;" {
+		t.Errorf("unexpected calls %s", data)
+	}
+	states := []NodeState{FailedNodeState, SkippedNodeState, NotTakenNodeState, SucceededNodeState, SkippedNodeState,
+		SucceededNodeState}
+	for index, state := range states {
+		if actual := flow.Report().GetState(flow.getNodes()[index]); actual != state {
+			t.Errorf("node %d: expected %s, got %s", index, state, actual)
+		}
+	}
+}
+
+func TestCatchReplacesFailure(t *testing.T) {
+	flow := NewFlow().Do(fail).Catch(MatchError(errTest), func(_data *_Data, _result *_Result) *_Result {
+		return &_Result{StatusCode: 7, StatusMsg: "replaced"}
+	})
+	if result := flow.Wait(); result.Err != nil || result.StatusCode != 7 {
+		t.Errorf("expected the failure to be replaced, got %+v", result)
+	}
+}
+
+func TestCatchScopedToTrySubPath(t *testing.T) {
+	handled := markCatch("handled")
+	flow := NewFlow().TrySubPath(NewFlow().Do(fail).Do(mark("skipped"))).Catch(nil, handled).Do(mark("after"))
+	if result := flow.Wait(); result.Err != nil || flow.getData().FunctionName != "handled;after;" {
+		t.Errorf("expected the failure of the sub-path to be handled, got %v, %s", result.Err,
+			flow.getData().FunctionName)
+	}
+
+	flow = NewFlow().Do(fail).TrySubPath(NewFlow().Do(succeed)).Catch(nil, handled)
+	if result := flow.Wait(); result.Err != errTest || flow.getData().FunctionName != "" {
+		t.Errorf("expected the failure before the sub-path to be kept, got %v, %s", result.Err,
+			flow.getData().FunctionName)
+	}
+}
+
+func TestMatchErrorType(t *testing.T) {
+	match := MatchErrorType(&FunctorNotFoundError{})
+	if !match(&_Result{Err: fmt.Errorf("wrapped: %w", NewFunctorNotFoundError("charge"))}) {
+		t.Error("expected the wrapped error to match")
+	}
+	if match(&_Result{Err: errTest}) || match(&_Result{StatusCode: 1}) {
+		t.Error("expected the other failures not to match")
+	}
+}
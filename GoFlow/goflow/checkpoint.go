@@ -0,0 +1,230 @@
+package goflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Checkpoint is the progress of a flow saved after each of its nodes completes
+type Checkpoint struct {
+	FlowID string `json:"flow_id"`
+	// NodeIndex is the index of the last completed node of the flow, the nodes of the sub-paths are not counted
+	NodeIndex int `json:"node_index"`
+	// States and Skips keep the branch decisions of the completed nodes, so that an Else after a taken If is still
+	// skipped after resuming
+	States []NodeState     `json:"states"`
+	Skips  []bool          `json:"skips"`
+	Data   json.RawMessage `json:"data"`
+	// Waiting is the name of the signal the flow is waiting for at the node after NodeIndex, empty if it is not
+	Waiting string `json:"waiting,omitempty"`
+}
+
+// ICheckpointStore keeps the checkpoints of the flows by their IDs. Load returns nil without error if the flow has no
+// checkpoint.
+type ICheckpointStore interface {
+	Save(checkpoint *Checkpoint) error
+	Load(flowID string) (*Checkpoint, error)
+	Delete(flowID string) error
+}
+
+type CheckpointMismatchError struct {
+	FlowID string
+	Nodes  int
+	Saved  int
+}
+
+func NewCheckpointMismatchError(flowID string, nodes int, saved int) *CheckpointMismatchError {
+	return &CheckpointMismatchError{FlowID: flowID, Nodes: nodes, Saved: saved}
+}
+
+func (c *CheckpointMismatchError) Error() string {
+	return fmt.Sprintf("checkpoint of flow %s has %d nodes while the flow has %d", c.FlowID, c.Saved, c.Nodes)
+}
+
+type checkpointer struct {
+	store  ICheckpointStore
+	flowID string
+}
+
+func (c *checkpointer) enabled() bool {
+	return c.store != nil && c.flowID != ""
+}
+
+// save records the node at index as completed. Nothing is saved once the flow fails, so that Resume runs the failed
+// node again. A checkpoint which can not be saved fails the flow.
+func (c *checkpointer) save(nodes []IBasicFlowNode, index int, data *_Data, result **_Result) {
+	if !c.enabled() || (*result).Err != nil || (*result).StatusCode != 0 {
+		return
+	}
+	c.write(nodes, index, "", data, result)
+}
+
+func (c *checkpointer) write(nodes []IBasicFlowNode, index int, waiting string, data *_Data, result **_Result) {
+	checkpoint := &Checkpoint{
+		FlowID:    c.flowID,
+		NodeIndex: index,
+		States:    make([]NodeState, 0, len(nodes)),
+		Skips:     make([]bool, 0, len(nodes)),
+		Waiting:   waiting,
+	}
+	for _, node := range nodes {
+		checkpoint.States = append(checkpoint.States, node.GetState())
+		checkpoint.Skips = append(checkpoint.Skips, node.GetShouldSkip())
+	}
+	var err error
+	if checkpoint.Data, err = json.Marshal(data); err == nil {
+		err = c.store.Save(checkpoint)
+	}
+	if err != nil {
+		*result = &_Result{Err: err}
+	}
+}
+
+// finish removes the checkpoint of a flow which completed successfully
+func (c *checkpointer) finish(result *_Result) {
+	if !c.enabled() || result.Err != nil || result.StatusCode != 0 {
+		return
+	}
+	_ = c.store.Delete(c.flowID)
+}
+
+// discard removes the checkpoint of a flow whose completed nodes have been compensated, which leaves nothing to resume
+func (c *checkpointer) discard() {
+	if c.enabled() {
+		_ = c.store.Delete(c.flowID)
+	}
+}
+
+// restore loads the checkpoint into the nodes and the data and returns the index of the first node to run
+func (c *checkpointer) restore(nodes []IBasicFlowNode, data *_Data) (int, error) {
+	if !c.enabled() {
+		return 0, nil
+	}
+	checkpoint, err := c.store.Load(c.flowID)
+	if err != nil || checkpoint == nil {
+		return 0, err
+	}
+	if len(checkpoint.States) != len(nodes) || len(checkpoint.Skips) != len(nodes) {
+		return 0, NewCheckpointMismatchError(c.flowID, len(nodes), len(checkpoint.States))
+	}
+	if err := json.Unmarshal(checkpoint.Data, data); err != nil {
+		return 0, err
+	}
+	for index, node := range nodes {
+		node.SetState(checkpoint.States[index])
+		node.SetShouldSkip(checkpoint.Skips[index])
+	}
+	return checkpoint.NodeIndex + 1, nil
+}
+
+// MemoryCheckpointStore keeps the checkpoints in the memory of the process, which is useful for tests and for
+// resuming a flow in the same process
+type MemoryCheckpointStore struct {
+	mutex       sync.Mutex
+	checkpoints map[string][]byte
+}
+
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[string][]byte)}
+}
+
+func (m *MemoryCheckpointStore) Save(checkpoint *Checkpoint) error {
+	content, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.checkpoints[checkpoint.FlowID] = content
+	return nil
+}
+
+func (m *MemoryCheckpointStore) Load(flowID string) (*Checkpoint, error) {
+	m.mutex.Lock()
+	content, ok := m.checkpoints[flowID]
+	m.mutex.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(content, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func (m *MemoryCheckpointStore) Delete(flowID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.checkpoints, flowID)
+	return nil
+}
+
+// FileCheckpointStore keeps every checkpoint as a JSON file in a directory
+type FileCheckpointStore struct {
+	Dir string
+}
+
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointStore{Dir: dir}, nil
+}
+
+func (f *FileCheckpointStore) path(flowID string) string {
+	return filepath.Join(f.Dir, url.PathEscape(flowID)+".json")
+}
+
+// Save writes to a temporary file first so that a crash in the middle never leaves a broken checkpoint
+func (f *FileCheckpointStore) Save(checkpoint *Checkpoint) error {
+	content, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(f.Dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(content); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), f.path(checkpoint.FlowID))
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+	return err
+}
+
+func (f *FileCheckpointStore) Load(flowID string) (*Checkpoint, error) {
+	content, err := ioutil.ReadFile(f.path(flowID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(content, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func (f *FileCheckpointStore) Delete(flowID string) error {
+	err := os.Remove(f.path(flowID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
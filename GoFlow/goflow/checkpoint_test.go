@@ -0,0 +1,99 @@
+package goflow
+
+import (
+	"os"
+	"testing"
+)
+
+// failFirst fails the first time it is called and succeeds afterwards
+func failFirst(name string) ICallable {
+	failed := false
+	return func(_data *_Data) *_Result {
+		_data.FunctionName += name + ";"
+		if !failed {
+			failed = true
+			return &_Result{Err: errTest}
+		}
+		return nil
+	}
+}
+
+func TestResumeContinuesAfterLastCompletedNode(t *testing.T) {
+	store := NewMemoryCheckpointStore()
+	flow := NewFlow().SetCheckpointStore(store).Do(mark("first")).Do(failFirst("second")).Do(mark("third"))
+
+	if result := flow.Resume("order-1"); result.Err != errTest {
+		t.Fatalf("expected the first run to fail, got %v", result.Err)
+	}
+	checkpoint, err := store.Load("order-1")
+	if err != nil || checkpoint == nil || checkpoint.NodeIndex != 0 {
+		t.Fatalf("expected the first node to be saved, got %+v, %v", checkpoint, err)
+	}
+
+	if result := flow.Resume("order-1"); result.Err != nil {
+		t.Fatalf("expected the second run to clear the failure, got %v", result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "first;second;second;third;" {
+		t.Errorf("expected the first node to run once, got %s", data)
+	}
+	if checkpoint, _ := store.Load("order-1"); checkpoint != nil {
+		t.Errorf("expected the checkpoint to be removed, got %+v", checkpoint)
+	}
+}
+
+func TestResumeKeepsBranchDecisions(t *testing.T) {
+	store := NewMemoryCheckpointStore()
+	flow := NewFlow().SetCheckpointStore(store)
+	flow.If(always, mark("if")).Else(mark("else")).Do(failFirst("last"))
+
+	flow.Resume("order-2")
+	flow.Resume("order-2")
+	if data := flow.getData().FunctionName; data != "if;last;last;" {
+		t.Errorf("expected the Else to stay skipped, got %s", data)
+	}
+	if state := flow.Report().GetState(flow.getNodes()[1]); state != SkippedNodeState {
+		t.Errorf("expected the Else to be skipped, got %s", state)
+	}
+}
+
+func TestResumeRefusesCheckpointOfAnotherFlow(t *testing.T) {
+	store := NewMemoryCheckpointStore()
+	if err := store.Save(&Checkpoint{FlowID: "order-3", States: []NodeState{SucceededNodeState}, Skips: []bool{false}}); err != nil {
+		t.Fatal(err)
+	}
+	result := NewFlow().SetCheckpointStore(store).Do(succeed).Do(succeed).Resume("order-3")
+	if mismatch, ok := result.Err.(*CheckpointMismatchError); !ok || mismatch.Nodes != 2 || mismatch.Saved != 1 {
+		t.Errorf("expected CheckpointMismatchError, got %v", result.Err)
+	}
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	store, err := NewFileCheckpointStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint, err := store.Load("order/4"); checkpoint != nil || err != nil {
+		t.Fatalf("expected no checkpoint, got %+v, %v", checkpoint, err)
+	}
+	saved := &Checkpoint{FlowID: "order/4", NodeIndex: 1, States: []NodeState{SucceededNodeState, NotTakenNodeState},
+		Skips: []bool{false, true}, Data: []byte("{}")}
+	if err := store.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Load("order/4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.NodeIndex != 1 || loaded.States[1] != NotTakenNodeState || !loaded.Skips[1] {
+		t.Errorf("unexpected checkpoint %+v", loaded)
+	}
+	if _, err := os.Stat(store.path("order/4")); err != nil {
+		t.Errorf("expected the checkpoint in the directory: %v", err)
+	}
+	if err := store.Delete("order/4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("order/4"); err != nil {
+		t.Errorf("expected deleting twice to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package goflow
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type CircuitState int64
+
+const (
+	ClosedCircuitState CircuitState = iota
+	OpenCircuitState
+	HalfOpenCircuitState
+)
+
+func (c CircuitState) String() string {
+	switch c {
+	case ClosedCircuitState:
+		return "Closed"
+	case OpenCircuitState:
+		return "Open"
+	case HalfOpenCircuitState:
+		return "HalfOpen"
+	}
+	return "Unknown"
+}
+
+type CircuitOpenError struct {
+	Name string
+}
+
+func NewCircuitOpenError(name string) *CircuitOpenError {
+	return &CircuitOpenError{Name: name}
+}
+
+func (c *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit %s is open", c.Name)
+}
+
+// CircuitBreaker stops calling a functor which keeps failing. It opens once FailureRate of the last Window calls have
+// failed, at least MinCalls of them, and then fails every call with CircuitOpenError, or calls Fallback instead.
+// After CoolDown, HalfOpenCalls calls are let through: the circuit closes again if all of them succeed and opens
+// again as soon as one fails. A call fails if its result has an error or a non-zero status code. Like TokenBucket, a
+// breaker is shared by the flows and follows its own Clock. A FailureRate of 0 or less never opens the circuit.
+type CircuitBreaker struct {
+	Name          string
+	FailureRate   float64
+	Window        int
+	MinCalls      int
+	CoolDown      time.Duration
+	HalfOpenCalls int
+	Fallback      ICallable
+	Clock         Clock
+
+	mutex     sync.Mutex
+	state     CircuitState
+	outcomes  []bool
+	next      int
+	openedAt  time.Time
+	trials    int
+	successes int
+	changes   []CircuitState
+}
+
+func NewCircuitBreaker(name string, failureRate float64, window int, coolDown time.Duration) *CircuitBreaker {
+	if window < 1 {
+		window = 1
+	}
+	return &CircuitBreaker{
+		Name:          name,
+		FailureRate:   failureRate,
+		Window:        window,
+		MinCalls:      window,
+		CoolDown:      coolDown,
+		HalfOpenCalls: 1,
+	}
+}
+
+// SetFallback sets the functor called instead of the guarded one while the circuit is open
+func (c *CircuitBreaker) SetFallback(functor ICallable) *CircuitBreaker {
+	c.Fallback = functor
+	return c
+}
+
+func (c *CircuitBreaker) SetClock(clock Clock) *CircuitBreaker {
+	c.Clock = clock
+	return c
+}
+
+func (c *CircuitBreaker) State() CircuitState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.state == OpenCircuitState && clockOr(c.Clock).Now().Sub(c.openedAt) >= c.CoolDown {
+		return HalfOpenCircuitState
+	}
+	return c.state
+}
+
+// setState must be called with the mutex held, the observers are told by notifyChanges once it is released
+func (c *CircuitBreaker) setState(state CircuitState) {
+	c.state = state
+	c.outcomes, c.next, c.trials, c.successes = c.outcomes[:0], 0, 0, 0
+	if state == OpenCircuitState {
+		c.openedAt = clockOr(c.Clock).Now()
+	}
+	c.changes = append(c.changes, state)
+}
+
+func (c *CircuitBreaker) notifyChanges() {
+	c.mutex.Lock()
+	changes := c.changes
+	c.changes = nil
+	c.mutex.Unlock()
+	for _, state := range changes {
+		notify(&Event{Kind: CircuitStateEvent, Circuit: c.Name, State: state})
+	}
+}
+
+// allow tells whether a call may go through
+func (c *CircuitBreaker) allow() bool {
+	defer c.notifyChanges()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.state == OpenCircuitState && clockOr(c.Clock).Now().Sub(c.openedAt) >= c.CoolDown {
+		c.setState(HalfOpenCircuitState)
+	}
+	switch c.state {
+	case OpenCircuitState:
+		return false
+	case HalfOpenCircuitState:
+		if c.trials >= c.HalfOpenCalls {
+			return false
+		}
+		c.trials++
+	}
+	return true
+}
+
+func (c *CircuitBreaker) record(failed bool) {
+	defer c.notifyChanges()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch c.state {
+	case HalfOpenCircuitState:
+		if failed {
+			c.setState(OpenCircuitState)
+		} else if c.successes++; c.successes >= c.HalfOpenCalls {
+			c.setState(ClosedCircuitState)
+		}
+	case ClosedCircuitState:
+		if len(c.outcomes) < c.Window {
+			c.outcomes = append(c.outcomes, failed)
+		} else {
+			c.outcomes[c.next] = failed
+		}
+		c.next = (c.next + 1) % c.Window
+		failures := 0
+		for _, outcome := range c.outcomes {
+			if outcome {
+				failures++
+			}
+		}
+		if c.FailureRate > 0 && len(c.outcomes) >= c.MinCalls && float64(failures) >= c.FailureRate*float64(len(c.outcomes)) {
+			c.setState(OpenCircuitState)
+		}
+	}
+}
+
+// wrap guards a functor with the breaker. A panic of the functor counts as a failure.
+func (c *CircuitBreaker) wrap(functor ICallable) ICallable {
+	return func(_data *_Data) *_Result {
+		if !c.allow() {
+			if c.Fallback != nil {
+				return c.Fallback(_data)
+			}
+			return &_Result{Err: NewCircuitOpenError(c.Name)}
+		}
+		completed := false
+		defer func() {
+			if !completed {
+				c.record(true)
+			}
+		}()
+		result := functor(_data)
+		completed = true
+		c.record(result != nil && (result.Err != nil || result.StatusCode != 0))
+		return result
+	}
+}
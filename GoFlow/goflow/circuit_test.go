@@ -0,0 +1,96 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAndCloses(t *testing.T) {
+	clock := newTestClock()
+	breaker := NewCircuitBreaker("dependency", 0.5, 4, time.Minute).SetClock(clock)
+	var states []CircuitState
+	observer := func(event *Event) {
+		if event.Kind == CircuitStateEvent && event.Circuit == "dependency" {
+			states = append(states, event.State)
+		}
+	}
+	defer AddObserver(observer)()
+
+	calls := 0
+	functor := breaker.wrap(func(_data *_Data) *_Result {
+		calls++
+		return fail(_data)
+	})
+	data := new(_Data)
+	for index := 0; index < 4; index++ {
+		if result := functor(data); result.Err != errTest {
+			t.Fatalf("expected call %d to fail as the functor did, got %v", index, result.Err)
+		}
+	}
+	if _, ok := functor(data).Err.(*CircuitOpenError); !ok || calls != 4 {
+		t.Fatalf("expected the open circuit to fail without calling, got %d calls", calls)
+	}
+
+	clock.advance(time.Minute)
+	if state := breaker.State(); state != HalfOpenCircuitState {
+		t.Fatalf("expected the circuit to be half-open after the cool down, got %s", state)
+	}
+	if result := breaker.wrap(succeed)(data); result != nil {
+		t.Fatalf("expected the trial call to go through, got %v", result.Err)
+	}
+	if state := breaker.State(); state != ClosedCircuitState {
+		t.Errorf("expected a successful trial to close the circuit, got %s", state)
+	}
+	expected := []CircuitState{OpenCircuitState, HalfOpenCircuitState, ClosedCircuitState}
+	if len(states) != len(expected) {
+		t.Fatalf("expected the observer to be told about %v, got %v", expected, states)
+	}
+	for index, state := range expected {
+		if states[index] != state {
+			t.Errorf("expected state change %d to be %s, got %s", index, state, states[index])
+		}
+	}
+}
+
+func TestCircuitBreakerReopensOnAFailedTrial(t *testing.T) {
+	clock := newTestClock()
+	breaker := NewCircuitBreaker("dependency", 1, 1, time.Minute).SetClock(clock)
+	functor := breaker.wrap(fail)
+	functor(new(_Data))
+	clock.advance(time.Minute)
+	functor(new(_Data))
+	if state := breaker.State(); state != OpenCircuitState {
+		t.Errorf("expected a failed trial to open the circuit again, got %s", state)
+	}
+}
+
+func TestCircuitBreakerWithoutFailureRateStaysClosed(t *testing.T) {
+	breaker := NewCircuitBreaker("dependency", 0, 2, time.Minute)
+	for index := 0; index < 5; index++ {
+		breaker.wrap(succeed)(new(_Data))
+		breaker.wrap(fail)(new(_Data))
+	}
+	if state := breaker.State(); state != ClosedCircuitState {
+		t.Errorf("expected a breaker without failure rate never to open, got %s", state)
+	}
+}
+
+func TestCircuitBreakerFallbackAndPanics(t *testing.T) {
+	breaker := NewCircuitBreaker("dependency", 1, 1, time.Minute).SetFallback(mark("fallback"))
+	flow := NewFlow().Do(func(_data *_Data) *_Result {
+		panic("broken")
+	}).SetCircuitBreaker(breaker)
+	handle := flow.Start(context.Background())
+	<-handle.Done()
+	if _, ok := handle.Result().Err.(*PanicHappened); !ok {
+		t.Fatal("expected the panic to fail the flow")
+	}
+	if state := breaker.State(); state != OpenCircuitState {
+		t.Fatalf("expected the panic to count as a failure, got %s", state)
+	}
+	flow = NewFlow().Do(mark("guarded")).SetCircuitBreaker(breaker)
+	if result := flow.Wait(); result.Err != nil || flow.getData().FunctionName != "fallback;" {
+		t.Errorf("expected the fallback to be called, got %q", flow.getData().FunctionName)
+	}
+}
@@ -0,0 +1,45 @@
+package goflow
+
+import "time"
+
+// Clock is where the time comes from, so that the features depending on it can be tested without waiting
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the part of time.Timer the library uses
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// clockOr returns the clock, or SystemClock if it is nil
+func clockOr(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
+
+type systemClock struct{}
+
+// SystemClock is the real time of the process, used unless another clock is set
+var SystemClock Clock = systemClock{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (s systemTimer) C() <-chan time.Time {
+	return s.Timer.C
+}
@@ -0,0 +1,57 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClockOrDefaultsToTheSystemClock(t *testing.T) {
+	if clockOr(nil) != SystemClock {
+		t.Error("expected SystemClock when no clock is set")
+	}
+	clock := newTestClock()
+	if clockOr(clock) != clock {
+		t.Error("expected the clock which is set")
+	}
+}
+
+func TestSystemClockTimers(t *testing.T) {
+	before := time.Now()
+	if now := SystemClock.Now(); now.Before(before) {
+		t.Errorf("expected the current time, got %v", now)
+	}
+
+	timer := SystemClock.NewTimer(time.Millisecond)
+	select {
+	case <-timer.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the timer to fire")
+	}
+	if timer.Stop() {
+		t.Error("expected a fired timer not to be active")
+	}
+
+	timer = SystemClock.NewTimer(time.Hour)
+	if !timer.Stop() {
+		t.Error("expected a waiting timer to be active")
+	}
+	timer.Reset(time.Millisecond)
+	select {
+	case <-timer.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the reset timer to fire")
+	}
+}
+
+func TestClockReachesTheSubPaths(t *testing.T) {
+	clock := newTestClock()
+	flow := NewFlow().SetClock(clock).IfSubPath(always, NewFlow().WaitForSignal("approve", time.Minute))
+	handle := flow.Start(context.Background())
+	clock.waitForTimers(t, 1)
+	clock.advance(time.Minute)
+	<-handle.Done()
+	if _, ok := handle.Result().Err.(*SignalTimeoutError); !ok {
+		t.Errorf("expected the sub-path to time out on the clock of the flow, got %v", handle.Result().Err)
+	}
+}
@@ -0,0 +1,113 @@
+package goflow
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// CompensationResult is the outcome of the compensation of one completed node
+type CompensationResult struct {
+	Node   IBasicFlowNode
+	Result *_Result
+}
+
+func (c *CompensationResult) Failed() bool {
+	return c.Result != nil && (c.Result.Err != nil || c.Result.StatusCode != 0)
+}
+
+// CompensationError replaces the error of a failed flow when some of its compensations fail as well. Err is the
+// original error of the flow, which is also returned by Unwrap.
+type CompensationError struct {
+	Err      error
+	Failures []*CompensationResult
+}
+
+func NewCompensationError(err error, failures []*CompensationResult) *CompensationError {
+	return &CompensationError{Err: err, Failures: failures}
+}
+
+func (c *CompensationError) Error() string {
+	messages := make([]string, 0, len(c.Failures))
+	for _, failure := range c.Failures {
+		if failure.Result.Err != nil {
+			messages = append(messages, failure.Result.Err.Error())
+		} else {
+			messages = append(messages, fmt.Sprintf("status code %d: %s", failure.Result.StatusCode, failure.Result.StatusMsg))
+		}
+	}
+	return fmt.Sprintf("%v, and %d compensation(s) failed: %s", c.Err, len(c.Failures), strings.Join(messages, "; "))
+}
+
+func (c *CompensationError) Unwrap() error {
+	return c.Err
+}
+
+// compensate runs the compensations of the completed nodes in the reverse order of their completion if the flow
+// failed. The result keeps the original failure, wrapped in CompensationError if any compensation fails.
+func compensate(engine IFlowEngine, data *_Data, result **_Result) []*CompensationResult {
+	if (*result).Err == nil && (*result).StatusCode == 0 {
+		return nil
+	}
+	completed := make([]IBasicFlowNode, 0)
+	walkCompleted(engine, func(node IBasicFlowNode) {
+		if node.GetCompensation() != nil {
+			completed = append(completed, node)
+		}
+	})
+
+	results := make([]*CompensationResult, 0, len(completed))
+	failures := make([]*CompensationResult, 0)
+	for i := len(completed) - 1; i >= 0; i-- {
+		compensation := &CompensationResult{Node: completed[i], Result: runCompensation(completed[i], data)}
+		results = append(results, compensation)
+		if compensation.Failed() {
+			failures = append(failures, compensation)
+		}
+	}
+	if len(failures) != 0 {
+		*result = &_Result{
+			Err:        NewCompensationError((*result).Err, failures),
+			StatusCode: (*result).StatusCode,
+			StatusMsg:  (*result).StatusMsg,
+		}
+	}
+	return results
+}
+
+// runCompensation calls the compensation of the node, a panic is a failed compensation with PanicHappened so that the
+// other compensations still run
+func runCompensation(node IBasicFlowNode, data *_Data) (result *_Result) {
+	defer func() {
+		if a := recover(); a != nil {
+			result = &_Result{Err: NewPanicHappened(string(debug.Stack()))}
+		}
+	}()
+	return node.GetCompensation()(data)
+}
+
+// walkCompleted visits the nodes which completed successfully in the order they completed, so the nodes of a
+// sub-path come before the node owning it
+func walkCompleted(engine IFlowEngine, visit func(node IBasicFlowNode)) {
+	if engine == nil {
+		return
+	}
+	for _, node := range engine.getNodes() {
+		// The sub-path of a failed node may have completed some nodes before failing
+		for _, subPath := range subPathsOf(node) {
+			walkCompleted(subPath, visit)
+		}
+		if node.GetState() == SucceededNodeState || node.GetState() == ReturnedNodeState {
+			visit(node)
+		}
+	}
+}
+
+// Compensations returns the outcome of the compensations run by the last Wait, in the order they ran
+func (f *FlowEngine) Compensations() []*CompensationResult {
+	return f.compensations
+}
+
+func (e *ElseFlowEngine) Compensations() []*CompensationResult {
+	return e.invoker.compensations
+}
@@ -0,0 +1,57 @@
+package goflow
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompensateUndoesCompletedNodesInReverse(t *testing.T) {
+	flow := NewFlow().
+		Do(mark("reserve")).Compensate(mark("release")).
+		IfSubPath(always, NewFlow().Do(mark("charge")).Compensate(mark("refund"))).
+		Do(fail).Compensate(mark("not-completed")).
+		Do(mark("skipped")).Compensate(mark("never"))
+
+	result := flow.Wait()
+	if result.Err != errTest {
+		t.Fatalf("expected the original failure, got %v", result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "reserve;charge;refund;release;" {
+		t.Errorf("unexpected calls %s", data)
+	}
+	compensations := flow.Compensations()
+	if len(compensations) != 2 || compensations[0].Failed() || compensations[1].Node != flow.getNodes()[0] {
+		t.Errorf("unexpected compensations %+v", compensations)
+	}
+}
+
+func TestCompensateReportsFailedCompensations(t *testing.T) {
+	broken := errors.New("refund failed")
+	flow := NewFlow().
+		Do(succeed).Compensate(func(_data *_Data) *_Result { panic("refund panicked") }).
+		Do(succeed).Compensate(func(_data *_Data) *_Result { return &_Result{Err: broken} }).
+		Do(mark("first")).Compensate(mark("undo")).
+		Do(fail)
+
+	result := flow.Wait()
+	compensationErr, ok := result.Err.(*CompensationError)
+	if !ok {
+		t.Fatalf("expected CompensationError, got %v", result.Err)
+	}
+	if compensationErr.Err != errTest || !errors.Is(result.Err, errTest) || len(compensationErr.Failures) != 2 {
+		t.Errorf("unexpected error %v", compensationErr)
+	}
+	if _, ok := compensationErr.Failures[1].Result.Err.(*PanicHappened); !ok {
+		t.Errorf("expected the panic to be a failure, got %v", compensationErr.Failures[1].Result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "first;undo;" {
+		t.Errorf("expected every compensation to run, got %s", data)
+	}
+}
+
+func TestCompensateDoesNothingOnSuccess(t *testing.T) {
+	flow := NewFlow().Do(succeed).Compensate(mark("undo"))
+	if result := flow.Wait(); result.Err != nil || flow.getData().FunctionName != "" || flow.Compensations() != nil {
+		t.Errorf("expected no compensation, got %v, %s", result.Err, flow.getData().FunctionName)
+	}
+}
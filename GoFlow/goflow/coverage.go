@@ -0,0 +1,307 @@
+package goflow
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BranchCoverage counts the decisions of one branch of a flow definition over the recorded runs. Taken counts the
+// runs in which the branch ran, and NotTaken the runs in which the condition of an If or an ElseIf was false.
+type BranchCoverage struct {
+	Depth     int
+	Kind      string
+	Condition string
+	Note      string
+	Reached   int
+	Taken     int
+	NotTaken  int
+
+	index int
+}
+
+// Conditional tells whether the branch has a false outcome to cover as well
+func (b *BranchCoverage) Conditional() bool {
+	switch b.Kind {
+	case "If", "ElseIf", "IfSubPath", "ElseIfSubPath":
+		return true
+	}
+	return false
+}
+
+// Missing returns the outcomes of the branch which never happened, "true" and "false" for a conditional branch or
+// "taken" for the others
+func (b *BranchCoverage) Missing() []string {
+	var missing []string
+	if b.Conditional() {
+		if b.Taken == 0 {
+			missing = append(missing, "true")
+		}
+		if b.NotTaken == 0 {
+			missing = append(missing, "false")
+		}
+	} else if b.Taken == 0 {
+		missing = append(missing, "taken")
+	}
+	return missing
+}
+
+func (b *BranchCoverage) label() string {
+	label := b.Kind
+	if b.Condition != "" {
+		label += " " + b.Condition
+	}
+	if b.Note != "" {
+		label += " (" + b.Note + ")"
+	}
+	return label
+}
+
+// FlowCoverage is the coverage of one flow definition, the branches are in the order of the nodes. Mismatched counts
+// the runs which were not recorded because their flow does not have the nodes of the definition.
+type FlowCoverage struct {
+	Name       string
+	Runs       int
+	Mismatched int
+	Branches   []*BranchCoverage
+
+	nodes int
+}
+
+// Outcomes returns how many outcomes of the branches happened at least once, out of how many there are
+func (f *FlowCoverage) Outcomes() (covered int, total int) {
+	for _, branch := range f.Branches {
+		outcomes := 1
+		if branch.Conditional() {
+			outcomes = 2
+		}
+		total += outcomes
+		covered += outcomes - len(branch.Missing())
+	}
+	return covered, total
+}
+
+func (f *FlowCoverage) Percent() float64 {
+	covered, total := f.Outcomes()
+	if total == 0 {
+		return 100
+	}
+	return float64(covered) * 100 / float64(total)
+}
+
+type CoverageMismatchError struct {
+	Name string
+}
+
+func NewCoverageMismatchError(name string) *CoverageMismatchError {
+	return &CoverageMismatchError{Name: name}
+}
+
+func (c *CoverageMismatchError) Error() string {
+	return fmt.Sprintf("flow %s does not have the nodes of the coverage recorded under its name", c.Name)
+}
+
+// Coverage collects the branch decisions of the runs of flows by the name of their definition, which is usually
+// built again for every run. It is safe for concurrent use.
+type Coverage struct {
+	mutex      sync.Mutex
+	flows      map[string]*FlowCoverage
+	mismatches []*CoverageMismatchError
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{flows: make(map[string]*FlowCoverage)}
+}
+
+// isCoverageBranch tells whether the decision of the node is covered: the branches of the If chains and the body of
+// the For loops
+func isCoverageBranch(node IBasicFlowNode) bool {
+	return isBranchHead(node) || isBranchTail(node) || node.GetNodeType() == ForNodeType
+}
+
+func newFlowCoverage(name string, engine IFlowEngine) *FlowCoverage {
+	coverage := &FlowCoverage{Name: name}
+	index := 0
+	walkNodes(engine, 0, func(node IBasicFlowNode, depth int) {
+		if isCoverageBranch(node) {
+			step := newPlanStep(node, depth)
+			coverage.Branches = append(coverage.Branches, &BranchCoverage{
+				Depth:     depth,
+				Kind:      step.Kind,
+				Condition: step.Condition,
+				Note:      step.Note,
+				index:     index,
+			})
+		}
+		index++
+	})
+	coverage.nodes = index
+	return coverage
+}
+
+// Record adds the states of the nodes left by the last Wait of the flow to the coverage of the definition name. It
+// fails with CoverageMismatchError if the flow does not have the same nodes as the flows recorded before under name.
+func (c *Coverage) Record(name string, engine IFlowEngine) error {
+	if err := c.record(name, engine); err != nil {
+		return err
+	}
+	return nil
+}
+
+// recordRun records a Wait of a flow given SetCoverage. The run does not fail on a mismatch, which is kept instead to
+// be reported by Mismatches, Text and WriteHTML.
+func (c *Coverage) recordRun(name string, engine IFlowEngine) {
+	if err := c.record(name, engine); err != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.flows[name].Mismatched++
+		c.mismatches = append(c.mismatches, err)
+	}
+}
+
+func (c *Coverage) record(name string, engine IFlowEngine) *CoverageMismatchError {
+	recorded := newFlowCoverage(name, engine)
+	nodes := make([]IBasicFlowNode, 0, recorded.nodes)
+	walkNodes(engine, 0, func(node IBasicFlowNode, depth int) {
+		nodes = append(nodes, node)
+	})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	coverage, ok := c.flows[name]
+	if !ok {
+		coverage = recorded
+		c.flows[name] = coverage
+	}
+	if coverage.nodes != recorded.nodes || len(coverage.Branches) != len(recorded.Branches) {
+		return NewCoverageMismatchError(name)
+	}
+	for index, branch := range coverage.Branches {
+		if branch.index != recorded.Branches[index].index || branch.Kind != recorded.Branches[index].Kind {
+			return NewCoverageMismatchError(name)
+		}
+	}
+
+	coverage.Runs++
+	for _, branch := range coverage.Branches {
+		node := nodes[branch.index]
+		switch node.GetState() {
+		case NotRunNodeState, SkippedNodeState:
+			continue
+		case NotTakenNodeState:
+			branch.NotTaken++
+		default:
+			if forNode, ok := node.(*ForNode); !ok || forNode.Times > 0 {
+				branch.Taken++
+			}
+		}
+		branch.Reached++
+	}
+	return nil
+}
+
+// Mismatches returns the runs of the flows given SetCoverage which were not recorded, in the order they happened
+func (c *Coverage) Mismatches() []*CoverageMismatchError {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*CoverageMismatchError(nil), c.mismatches...)
+}
+
+// Flows returns a copy of the coverage of every flow definition, sorted by name
+func (c *Coverage) Flows() []*FlowCoverage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	flows := make([]*FlowCoverage, 0, len(c.flows))
+	for _, flow := range c.flows {
+		copied := *flow
+		copied.Branches = make([]*BranchCoverage, 0, len(flow.Branches))
+		for _, branch := range flow.Branches {
+			copiedBranch := *branch
+			copied.Branches = append(copied.Branches, &copiedBranch)
+		}
+		flows = append(flows, &copied)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].Name < flows[j].Name
+	})
+	return flows
+}
+
+// Text reports the coverage of every flow definition, one line per branch, marking the outcomes never seen
+func (c *Coverage) Text() string {
+	builder := &strings.Builder{}
+	for _, flow := range c.Flows() {
+		covered, total := flow.Outcomes()
+		builder.WriteString(fmt.Sprintf("flow %s: %d runs, %d/%d outcomes covered (%.1f%%)\n", flow.Name, flow.Runs,
+			covered, total, flow.Percent()))
+		if flow.Mismatched != 0 {
+			builder.WriteString(fmt.Sprintf("    %d runs not recorded: their flow does not match the definition\n",
+				flow.Mismatched))
+		}
+		for _, branch := range flow.Branches {
+			builder.WriteString(strings.Repeat("    ", branch.Depth+1))
+			builder.WriteString(branch.label())
+			if branch.Conditional() {
+				builder.WriteString(fmt.Sprintf(": true %d, false %d", branch.Taken, branch.NotTaken))
+			} else {
+				builder.WriteString(fmt.Sprintf(": taken %d", branch.Taken))
+			}
+			if missing := branch.Missing(); len(missing) != 0 {
+				builder.WriteString(" => NEVER " + strings.Join(missing, ", "))
+			}
+			builder.WriteString("\n")
+		}
+	}
+	return builder.String()
+}
+
+var coverageTemplate = template.Must(template.New("coverage").Funcs(template.FuncMap{
+	"indent": func(depth int) int { return depth * 24 },
+	"join":   strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Flow Coverage</title>
+<style>
+body { font-family: monospace; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+tr.covered { background: #e6ffe6; }
+tr.missing { background: #ffe6e6; }
+p.mismatched { color: #c00; }
+</style>
+</head>
+<body>
+{{range .}}<h2>{{.Name}}: {{.Runs}} runs, {{printf "%.1f" .Percent}}%</h2>
+{{if .Mismatched}}<p class="mismatched">{{.Mismatched}} runs not recorded: their flow does not match the definition</p>
+{{end}}<table>
+<tr><th>Branch</th><th>True / Taken</th><th>False</th><th>Never</th></tr>
+{{range .Branches}}{{$missing := .Missing}}<tr class="{{if $missing}}missing{{else}}covered{{end}}">
+<td style="padding-left: {{indent .Depth}}px">{{.Kind}}{{if .Condition}} {{.Condition}}{{end}}{{if .Note}} ({{.Note}}){{end}}</td>
+<td>{{.Taken}}</td><td>{{if .Conditional}}{{.NotTaken}}{{end}}</td><td>{{join $missing ", "}}</td>
+</tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes the coverage as an HTML page, the branches with an outcome never seen are red
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	return coverageTemplate.Execute(w, c.Flows())
+}
+
+// SetCoverage records the branch decisions of every Wait of the flow in the coverage under the definition name. A run
+// whose flow does not match the definition is not recorded, it is reported as a mismatch by the coverage.
+func (f *FlowEngine) SetCoverage(coverage *Coverage, name string) *FlowEngine {
+	f.coverage, f.coverageName = coverage, name
+	return f
+}
+
+func (e *ElseFlowEngine) SetCoverage(coverage *Coverage, name string) *ElseFlowEngine {
+	e.invoker.coverage, e.invoker.coverageName = coverage, name
+	return e
+}
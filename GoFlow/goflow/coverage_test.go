@@ -0,0 +1,70 @@
+package goflow
+
+import (
+	"bytes"
+	"testing"
+)
+
+func coveredFlow(coverage *Coverage, vip bool) *FlowEngine {
+	condition := never
+	if vip {
+		condition = always
+	}
+	return NewFlow().If(condition, mark("vip")).SetNote("vip").Else(mark("regular")).SetNote("regular").
+		For(2, mark("loop")).SetNote("loop").SetCoverage(coverage, "order")
+}
+
+func TestCoverageCountsTheOutcomes(t *testing.T) {
+	coverage := NewCoverage()
+	coveredFlow(coverage, true).Wait()
+	flows := coverage.Flows()
+	if len(flows) != 1 || flows[0].Runs != 1 || len(flows[0].Branches) != 3 {
+		t.Fatalf("expected one run of three branches, got %d flows", len(flows))
+	}
+	branches := flows[0].Branches
+	if branches[0].Taken != 1 || branches[1].Taken != 0 || branches[2].Taken != 1 {
+		t.Errorf("unexpected counts %d, %d and %d", branches[0].Taken, branches[1].Taken, branches[2].Taken)
+	}
+	if covered, total := flows[0].Outcomes(); covered != 2 || total != 4 {
+		t.Errorf("expected 2 of 4 outcomes covered, got %d of %d", covered, total)
+	}
+	assertContains(t, coverage.Text(), "flow order: 1 runs, 2/4 outcomes covered (50.0%)", "=> NEVER false",
+		"=> NEVER taken")
+
+	coveredFlow(coverage, false).Wait()
+	if percent := coverage.Flows()[0].Percent(); percent != 100 {
+		t.Errorf("expected every outcome to be covered, got %.1f%%", percent)
+	}
+}
+
+func TestCoverageReportsTheMismatches(t *testing.T) {
+	coverage := NewCoverage()
+	coveredFlow(coverage, true).Wait()
+	NewFlow().Do(mark("other")).SetCoverage(coverage, "order").Wait()
+	NewFlow().If(always, mark("other")).SetCoverage(coverage, "order").Wait()
+
+	mismatches := coverage.Mismatches()
+	if len(mismatches) != 2 || mismatches[0].Name != "order" {
+		t.Fatalf("expected the two other flows to be mismatches, got %v", mismatches)
+	}
+	if flow := coverage.Flows()[0]; flow.Runs != 1 || flow.Mismatched != 2 {
+		t.Errorf("expected 1 run and 2 mismatches, got %d and %d", flow.Runs, flow.Mismatched)
+	}
+	assertContains(t, coverage.Text(), "2 runs not recorded: their flow does not match the definition")
+	html := &bytes.Buffer{}
+	if err := coverage.WriteHTML(html); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, html.String(), "2 runs not recorded: their flow does not match the definition")
+
+	err := coverage.Record("order", NewFlow().Do(mark("other")))
+	if _, ok := err.(*CoverageMismatchError); !ok {
+		t.Errorf("expected CoverageMismatchError, got %v", err)
+	}
+	if len(coverage.Mismatches()) != 2 {
+		t.Error("expected Record to return its mismatch rather than keep it")
+	}
+	if err := coverage.Record("order", coveredFlow(NewCoverage(), false)); err != nil {
+		t.Errorf("unexpected failure %v", err)
+	}
+}
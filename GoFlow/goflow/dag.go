@@ -0,0 +1,331 @@
+package goflow
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// DAGStep is a named step of a DAG. It runs its functors in order once all the steps it depends on have succeeded.
+type DAGStep struct {
+	Name      string
+	DependsOn []string
+	Functors  []ICallable
+	Note      string
+	State     NodeState
+	// Result is the failure of the step, or nil if it did not fail
+	Result *_Result
+	// names are the names the functors were given by to StepNamed
+	names []string
+}
+
+// DAG runs steps in the order given by their dependencies instead of the order they are added. The steps which do
+// not depend on each other run concurrently, sharing the data like the functors of Parallel do. Every step runs as a
+// Do node of a flow would, so the rate limits and circuit breakers of the registry, the observers, the clock and the
+// tape apply to its functors.
+type DAG struct {
+	data          *_Data
+	steps         []*DAGStep
+	limit         int
+	registry      *Registry
+	clock         Clock
+	tape          tape
+	onFailFunc    IOnFailFunc
+	onSuccessFunc IOnSuccessFunc
+}
+
+func NewDAG() *DAG {
+	res := &DAG{data: new(_Data), registry: DefaultRegistry}
+	InitStructure(res.data)
+	return res
+}
+
+type UnknownDependencyError struct {
+	Step       string
+	Dependency string
+}
+
+func NewUnknownDependencyError(step string, dependency string) *UnknownDependencyError {
+	return &UnknownDependencyError{Step: step, Dependency: dependency}
+}
+
+func (u *UnknownDependencyError) Error() string {
+	return fmt.Sprintf("step %q depends on unknown step %q", u.Step, u.Dependency)
+}
+
+// CycleError lists the steps of a dependency cycle, the first step is repeated at the end
+type CycleError struct {
+	Steps []string
+}
+
+func NewCycleError(steps []string) *CycleError {
+	return &CycleError{Steps: steps}
+}
+
+func (c *CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(c.Steps, " -> ")
+}
+
+// Step adds a step named name which runs after the steps in dependsOn
+func (d *DAG) Step(name string, dependsOn []string, functors ...ICallable) *DAG {
+	d.steps = append(d.steps, &DAGStep{
+		Name:      name,
+		DependsOn: dependsOn,
+		Functors:  functors,
+	})
+	return d
+}
+
+// StepNamed is Step with the names the callables are registered with in the registry of the DAG. A name which is not
+// registered makes the step fail with FunctorNotFoundError.
+func (d *DAG) StepNamed(name string, dependsOn []string, functors ...string) *DAG {
+	d.Step(name, dependsOn, d.registry.callablesNamed(functors)...)
+	d.steps[len(d.steps)-1].names = functors
+	return d
+}
+
+func (d *DAG) SetNote(note string) *DAG {
+	if len(d.steps) != 0 {
+		d.steps[len(d.steps)-1].Note = note
+	}
+	return d
+}
+
+// SetLimit sets the maximum number of steps running at the same time, 0 means no limit
+func (d *DAG) SetLimit(limit int) *DAG {
+	d.limit = limit
+	return d
+}
+
+func (d *DAG) SetRegistry(registry *Registry) *DAG {
+	d.registry = registry
+	return d
+}
+
+// SetClock sets the clock the steps read the time from, SystemClock by default
+func (d *DAG) SetClock(clock Clock) *DAG {
+	d.clock = clock
+	return d
+}
+
+// SetRecorder records the calls of the functors of every Wait of the DAG
+func (d *DAG) SetRecorder(recorder *Recorder) *DAG {
+	d.tape = nil
+	if recorder != nil {
+		d.tape = recorder
+	}
+	return d
+}
+
+// SetReplayer makes every Wait of the DAG replay the recording of the replayer instead of calling the functors
+func (d *DAG) SetReplayer(replayer *Replayer) *DAG {
+	d.tape = nil
+	if replayer != nil {
+		d.tape = replayer
+	}
+	return d
+}
+
+func (d *DAG) OnFail(functor IOnFailFunc) *DAG {
+	d.onFailFunc = functor
+	return d
+}
+
+func (d *DAG) OnSuccess(functor IOnSuccessFunc) *DAG {
+	d.onSuccessFunc = functor
+	return d
+}
+
+func (d *DAG) GetData() *_Data {
+	return d.data
+}
+
+// Steps returns the steps in the order they were added, with the states left by the last Wait
+func (d *DAG) Steps() []*DAGStep {
+	return d.steps
+}
+
+// Validate checks that the names are unique, that every dependency exists and that there is no cycle
+func (d *DAG) Validate() error {
+	steps := make(map[string]*DAGStep, len(d.steps))
+	for _, step := range d.steps {
+		if _, ok := steps[step.Name]; ok {
+			return NewDuplicateNameError("step", step.Name)
+		}
+		steps[step.Name] = step
+	}
+	for _, step := range d.steps {
+		for _, dependency := range step.DependsOn {
+			if _, ok := steps[dependency]; !ok {
+				return NewUnknownDependencyError(step.Name, dependency)
+			}
+		}
+	}
+
+	// Depth first search, a step met again while it is still on the path closes a cycle
+	const (
+		unvisited = iota
+		onPath
+		visited
+	)
+	marks := make(map[string]int, len(d.steps))
+	path := make([]string, 0, len(d.steps))
+	var visit func(step *DAGStep) error
+	visit = func(step *DAGStep) error {
+		marks[step.Name] = onPath
+		path = append(path, step.Name)
+		for _, dependency := range step.DependsOn {
+			switch marks[dependency] {
+			case onPath:
+				for index, name := range path {
+					if name == dependency {
+						return NewCycleError(append(append([]string{}, path[index:]...), dependency))
+					}
+				}
+			case unvisited:
+				if err := visit(steps[dependency]); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		marks[step.Name] = visited
+		return nil
+	}
+	for _, step := range d.steps {
+		if marks[step.Name] == unvisited {
+			if err := visit(step); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type dagOutcome struct {
+	step   *DAGStep
+	result *_Result
+}
+
+// Wait runs the steps whose dependencies have succeeded, at most limit of them at the same time. Once a step fails or
+// the context of the data is done, no new step is started, the running ones are waited for and the first failure is
+// returned. A step returning Return ends the DAG successfully in the same way.
+func (d *DAG) Wait() *_Result {
+	for _, step := range d.steps {
+		step.State, step.Result = NotRunNodeState, nil
+	}
+	result := &_Result{}
+	if d.tape != nil {
+		d.tape.begin(d.data, &result)
+	}
+	if err := d.Validate(); err != nil {
+		result = &_Result{Err: err}
+	} else if result.Err == nil {
+		result = d.run()
+	}
+
+	if d.onSuccessFunc != nil {
+		if result.Err == nil && result.StatusCode == 0 {
+			d.onSuccessFunc(d.data, result)
+		}
+	}
+	if d.onFailFunc != nil {
+		if result.Err != nil || result.StatusCode != 0 {
+			d.onFailFunc(d.data, result)
+		}
+	}
+	if d.tape != nil {
+		d.tape.end(result)
+	}
+	return result
+}
+
+func (d *DAG) run() *_Result {
+	waiting := make(map[string]int, len(d.steps))
+	dependents := make(map[string][]*DAGStep, len(d.steps))
+	ready := make([]*DAGStep, 0, len(d.steps))
+	for _, step := range d.steps {
+		waiting[step.Name] = len(step.DependsOn)
+		for _, dependency := range step.DependsOn {
+			dependents[dependency] = append(dependents[dependency], step)
+		}
+		if len(step.DependsOn) == 0 {
+			ready = append(ready, step)
+		}
+	}
+
+	result := &_Result{}
+	stopped := false
+	running := 0
+	outcomes := make(chan *dagOutcome, len(d.steps))
+	for {
+		if !stopped {
+			cancelled(GetContext(d.data), &result)
+			stopped = result.Err != nil
+		}
+		for !stopped && len(ready) != 0 && (d.limit <= 0 || running < d.limit) {
+			step := ready[0]
+			ready = ready[1:]
+			step.State = RunningNodeState
+			running++
+			go d.runStep(step, outcomes)
+		}
+		if running == 0 {
+			break
+		}
+
+		outcome := <-outcomes
+		running--
+		switch {
+		case outcome.result == Return:
+			outcome.step.State = ReturnedNodeState
+			stopped = true
+		case outcome.result != nil:
+			outcome.step.State, outcome.step.Result = FailedNodeState, outcome.result
+			if !stopped {
+				result = outcome.result
+			}
+			stopped = true
+		default:
+			outcome.step.State = SucceededNodeState
+			for _, dependent := range dependents[outcome.step.Name] {
+				waiting[dependent.Name]--
+				if waiting[dependent.Name] == 0 {
+					ready = append(ready, dependent)
+				}
+			}
+		}
+	}
+
+	for _, step := range d.steps {
+		if step.State == NotRunNodeState {
+			step.State = SkippedNodeState
+		}
+	}
+	return result
+}
+
+// runStep runs the step as a Do node and sends Return, the failure of the node or nil if it succeeds
+func (d *DAG) runStep(step *DAGStep, outcomes chan<- *dagOutcome) {
+	outcome := &dagOutcome{step: step}
+	defer func() {
+		if a := recover(); a != nil {
+			outcome.result = &_Result{Err: NewPanicHappened(string(debug.Stack()))}
+		}
+		outcomes <- outcome
+	}()
+	result := &_Result{}
+	node := NewNormalNode(d.data, &result, step.Functors...)
+	node.SetNote(step.Note)
+	node.getNames().functors = step.names
+	node.SetRegistry(d.registry)
+	node.SetClock(d.clock)
+	node.setTape(d.tape, step.Name)
+	runNode(node, d.data, result)
+	switch node.GetState() {
+	case ReturnedNodeState:
+		outcome.result = Return
+	case FailedNodeState:
+		outcome.result = result
+	}
+}
@@ -0,0 +1,131 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDAGRunsStepsAfterTheirDependencies(t *testing.T) {
+	dag := NewDAG().
+		Step("third", []string{"second"}, mark("third")).
+		Step("second", []string{"first"}, mark("second")).
+		Step("first", nil, mark("first"))
+	result := dag.Wait()
+	if result.Err != nil || result.StatusCode != 0 {
+		t.Fatalf("unexpected failure %v", result.Err)
+	}
+	if order := dag.GetData().FunctionName; order != "first;second;third;" {
+		t.Errorf("unexpected order %s", order)
+	}
+	for _, step := range dag.Steps() {
+		if step.State != SucceededNodeState {
+			t.Errorf("expected step %s to succeed, got %s", step.Name, step.State)
+		}
+	}
+}
+
+func TestDAGValidates(t *testing.T) {
+	result := NewDAG().Step("first", nil, succeed).Step("first", nil, succeed).Wait()
+	if _, ok := result.Err.(*DuplicateNameError); !ok {
+		t.Errorf("expected DuplicateNameError, got %v", result.Err)
+	}
+	result = NewDAG().Step("first", []string{"missing"}, succeed).Wait()
+	if _, ok := result.Err.(*UnknownDependencyError); !ok {
+		t.Errorf("expected UnknownDependencyError, got %v", result.Err)
+	}
+	result = NewDAG().
+		Step("first", []string{"second"}, succeed).
+		Step("second", []string{"first"}, succeed).
+		Wait()
+	if cycle, ok := result.Err.(*CycleError); !ok || len(cycle.Steps) != 3 {
+		t.Errorf("expected a CycleError of two steps, got %v", result.Err)
+	}
+}
+
+func TestDAGStopsAfterAFailure(t *testing.T) {
+	dag := NewDAG().
+		Step("first", nil, fail).
+		Step("second", []string{"first"}, mark("second"))
+	result := dag.Wait()
+	if result.Err != errTest {
+		t.Fatalf("expected the failure of the step, got %v", result.Err)
+	}
+	steps := dag.Steps()
+	if steps[0].State != FailedNodeState || steps[0].Result != result {
+		t.Errorf("expected the first step to keep its failure, got %s", steps[0].State)
+	}
+	if steps[1].State != SkippedNodeState || dag.GetData().FunctionName != "" {
+		t.Errorf("expected the second step to be skipped, got %s", steps[1].State)
+	}
+}
+
+func TestDAGEndsOnReturn(t *testing.T) {
+	dag := NewDAG().
+		Step("first", nil, returnEarly, mark("first")).
+		Step("second", []string{"first"}, mark("second"))
+	result := dag.Wait()
+	if result.Err != nil || result.StatusCode != 0 {
+		t.Fatalf("unexpected failure %v", result.Err)
+	}
+	steps := dag.Steps()
+	if steps[0].State != ReturnedNodeState || steps[1].State != SkippedNodeState {
+		t.Errorf("unexpected states %s and %s", steps[0].State, steps[1].State)
+	}
+	if dag.GetData().FunctionName != "return;" {
+		t.Errorf("expected nothing to run after Return, got %s", dag.GetData().FunctionName)
+	}
+}
+
+func TestDAGStopsOnceTheContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dag := NewDAG().Step("first", nil, mark("first"))
+	SetContext(dag.GetData(), ctx)
+	result := dag.Wait()
+	if result.Err != context.Canceled {
+		t.Errorf("expected the DAG to be cancelled, got %v", result.Err)
+	}
+	if dag.Steps()[0].State != SkippedNodeState {
+		t.Errorf("expected the step to be skipped, got %s", dag.Steps()[0].State)
+	}
+}
+
+func TestDAGStepsGoThroughTheRegistry(t *testing.T) {
+	registry := NewRegistry()
+	limiter := &countingLimiter{}
+	errs := []error{
+		registry.RegisterCallable("first", mark("first")),
+		registry.RegisterCallable("second", mark("second")),
+		registry.SetRateLimit("first", limiter),
+	}
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recorder := NewRecorder()
+	dag := NewDAG().SetRegistry(registry).SetRecorder(recorder).
+		StepNamed("load", nil, "first", "second").SetNote("load")
+	if result := dag.Wait(); result.Err != nil {
+		t.Fatalf("unexpected failure %v", result.Err)
+	}
+	if limiter.waits != 1 {
+		t.Errorf("expected the functor given by name to be limited once, got %d", limiter.waits)
+	}
+	recording, err := recorder.Recording()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recording.Calls) != 2 || recording.Calls[0].Functor != "first" || recording.Calls[0].Note != "load" {
+		t.Fatalf("expected the calls of the step to be recorded by name, got %d calls", len(recording.Calls))
+	}
+	if recording.Calls[0].Node != "load" {
+		t.Errorf("expected the calls to be made by the step, got node %s", recording.Calls[0].Node)
+	}
+
+	result := NewDAG().SetRegistry(registry).StepNamed("missing", nil, "missing").Wait()
+	if _, ok := result.Err.(*FunctorNotFoundError); !ok {
+		t.Errorf("expected FunctorNotFoundError, got %v", result.Err)
+	}
+}
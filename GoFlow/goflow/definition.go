@@ -0,0 +1,508 @@
+package goflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FlowDefinition is the declarative form of a flow. It is loaded from a YAML or JSON document such as
+//
+//	steps:
+//	  - prepare: [load-order]
+//	  - do: [validate, reserve]
+//	    note: check the stock
+//	  - if: is-vip
+//	    do: [apply-discount]
+//	  - elseif: has-coupon
+//	    subpath:
+//	      - do: [apply-coupon]
+//	  - else: true
+//	    do: [charge-full-price]
+//	  - for: 3
+//	    do: [notify]
+//	  - parallel: [audit, ship]
+//	  - finally: true
+//	    do: [release-lock]
+//
+// and the names are looked up in a Registry when the flow is built.
+type FlowDefinition struct {
+	Steps []*StepDefinition `json:"steps" yaml:"steps"`
+}
+
+// StepDefinition describes one node. The kind of the node is given by the first of prepare, if, elseif, else, finally,
+// for, parallel and do which is set. If, ElseIf, Else and Finally take either do or subpath as their body.
+type StepDefinition struct {
+	Prepare  []string          `json:"prepare,omitempty" yaml:"prepare,omitempty"`
+	If       string            `json:"if,omitempty" yaml:"if,omitempty"`
+	ElseIf   string            `json:"elseif,omitempty" yaml:"elseif,omitempty"`
+	Else     bool              `json:"else,omitempty" yaml:"else,omitempty"`
+	Finally  bool              `json:"finally,omitempty" yaml:"finally,omitempty"`
+	For      *int              `json:"for,omitempty" yaml:"for,omitempty"`
+	Parallel []string          `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+	Do       []string          `json:"do,omitempty" yaml:"do,omitempty"`
+	SubPath  []*StepDefinition `json:"subpath,omitempty" yaml:"subpath,omitempty"`
+	Note     string            `json:"note,omitempty" yaml:"note,omitempty"`
+	// Compensate names the callable undoing the step if the flow fails later
+	Compensate string `json:"compensate,omitempty" yaml:"compensate,omitempty"`
+	// Line is the line of the step in the document it was loaded from, 0 if it was not loaded from a document
+	Line int `json:"-" yaml:"-"`
+}
+
+type StepKind int64
+
+const (
+	DoStepKind StepKind = iota
+	PrepareStepKind
+	IfStepKind
+	ElseIfStepKind
+	ElseStepKind
+	ForStepKind
+	ParallelStepKind
+	FinallyStepKind
+)
+
+func (s *StepDefinition) Kind() StepKind {
+	switch {
+	case s.Prepare != nil:
+		return PrepareStepKind
+	case s.If != "":
+		return IfStepKind
+	case s.ElseIf != "":
+		return ElseIfStepKind
+	case s.Else:
+		return ElseStepKind
+	case s.Finally:
+		return FinallyStepKind
+	case s.For != nil:
+		return ForStepKind
+	case s.Parallel != nil:
+		return ParallelStepKind
+	}
+	return DoStepKind
+}
+
+type DefinitionError struct {
+	Line int
+	Msg  string
+}
+
+func NewDefinitionError(line int, format string, args ...interface{}) *DefinitionError {
+	return &DefinitionError{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (d *DefinitionError) Error() string {
+	if d.Line > 0 {
+		return fmt.Sprintf("line %d: %s", d.Line, d.Msg)
+	}
+	return d.Msg
+}
+
+// DefinitionErrors collects every problem found in a document so that they can be fixed at once
+type DefinitionErrors []*DefinitionError
+
+func (d DefinitionErrors) Error() string {
+	messages := make([]string, 0, len(d))
+	for _, err := range d {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (d DefinitionErrors) orNil() error {
+	if len(d) == 0 {
+		return nil
+	}
+	return d
+}
+
+// ParseFlowDefinition parses a YAML or JSON document and checks its structure. The names are not resolved until
+// Validate or Build is called with a Registry.
+func ParseFlowDefinition(document []byte) (*FlowDefinition, error) {
+	trimmed := bytes.TrimSpace(document)
+	if len(trimmed) != 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		// JSON is parsed as YAML to keep the line numbers. Tabs can not appear inside JSON strings, so replacing
+		// them keeps the document intact while YAML does not accept them as indentation.
+		document = bytes.ReplaceAll(document, []byte("\t"), []byte(" "))
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(document, &root); err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil, DefinitionErrors{NewDefinitionError(0, "document is empty")}
+	}
+
+	var errs DefinitionErrors
+	definition := &FlowDefinition{}
+	node := root.Content[0]
+	if node.Kind != yaml.MappingNode {
+		return nil, DefinitionErrors{NewDefinitionError(node.Line, "document must be a mapping with steps")}
+	}
+	foundSteps := false
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value != "steps" {
+			errs = append(errs, NewDefinitionError(key.Line, "unknown key %q", key.Value))
+			continue
+		}
+		foundSteps = true
+		definition.Steps = parseSteps(value, &errs)
+	}
+	if !foundSteps {
+		errs = append(errs, NewDefinitionError(node.Line, "steps is missing"))
+	}
+	checkSteps(definition.Steps, &errs)
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	return definition, nil
+}
+
+func parseSteps(node *yaml.Node, errs *DefinitionErrors) []*StepDefinition {
+	if node.Kind != yaml.SequenceNode {
+		*errs = append(*errs, NewDefinitionError(node.Line, "steps must be a list"))
+		return nil
+	}
+	steps := make([]*StepDefinition, 0, len(node.Content))
+	for _, item := range node.Content {
+		if step := parseStep(item, errs); step != nil {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func parseStep(node *yaml.Node, errs *DefinitionErrors) *StepDefinition {
+	if node.Kind != yaml.MappingNode {
+		*errs = append(*errs, NewDefinitionError(node.Line, "step must be a mapping"))
+		return nil
+	}
+	step := &StepDefinition{Line: node.Line}
+	kinds := make([]string, 0, 1)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "prepare":
+			step.Prepare = parseNames(key.Value, value, errs)
+			kinds = append(kinds, key.Value)
+		case "if":
+			step.If = parseName(key.Value, value, errs)
+			kinds = append(kinds, key.Value)
+		case "elseif":
+			step.ElseIf = parseName(key.Value, value, errs)
+			kinds = append(kinds, key.Value)
+		case "else":
+			if value.Tag != "!!null" && !(value.Tag == "!!bool" && value.Value == "true") {
+				*errs = append(*errs, NewDefinitionError(value.Line, "else must be empty or true"))
+			}
+			step.Else = true
+			kinds = append(kinds, key.Value)
+		case "finally":
+			if value.Tag != "!!null" && !(value.Tag == "!!bool" && value.Value == "true") {
+				*errs = append(*errs, NewDefinitionError(value.Line, "finally must be empty or true"))
+			}
+			step.Finally = true
+			kinds = append(kinds, key.Value)
+		case "for":
+			times, err := strconv.Atoi(value.Value)
+			if value.Kind != yaml.ScalarNode || err != nil || times < 0 {
+				*errs = append(*errs, NewDefinitionError(value.Line, "for must be a non-negative integer"))
+			}
+			step.For = &times
+			kinds = append(kinds, key.Value)
+		case "parallel":
+			step.Parallel = parseNames(key.Value, value, errs)
+			kinds = append(kinds, key.Value)
+		case "do":
+			step.Do = parseNames(key.Value, value, errs)
+		case "subpath":
+			step.SubPath = parseSteps(value, errs)
+			if step.SubPath == nil {
+				step.SubPath = []*StepDefinition{}
+			}
+		case "note":
+			step.Note = parseName(key.Value, value, errs)
+		case "compensate":
+			step.Compensate = parseName(key.Value, value, errs)
+		default:
+			*errs = append(*errs, NewDefinitionError(key.Line, "unknown key %q", key.Value))
+		}
+	}
+	if len(kinds) > 1 {
+		*errs = append(*errs, NewDefinitionError(step.Line, "step can only be one of %s", strings.Join(kinds, ", ")))
+	}
+	return step
+}
+
+func parseName(key string, node *yaml.Node, errs *DefinitionErrors) string {
+	if node.Kind != yaml.ScalarNode || node.Value == "" {
+		*errs = append(*errs, NewDefinitionError(node.Line, "%s must be a name", key))
+		return ""
+	}
+	return node.Value
+}
+
+// parseNames accepts either a single name or a list of names
+func parseNames(key string, node *yaml.Node, errs *DefinitionErrors) []string {
+	if node.Kind == yaml.ScalarNode {
+		return []string{parseName(key, node, errs)}
+	}
+	if node.Kind != yaml.SequenceNode {
+		*errs = append(*errs, NewDefinitionError(node.Line, "%s must be a name or a list of names", key))
+		return []string{}
+	}
+	names := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		names = append(names, parseName(key, item, errs))
+	}
+	return names
+}
+
+// checkSteps checks the combination of the keys of every step and the order of the If chains
+func checkSteps(steps []*StepDefinition, errs *DefinitionErrors) {
+	inChain := false
+	for _, step := range steps {
+		kind := step.Kind()
+		switch kind {
+		case IfStepKind, ElseIfStepKind, ElseStepKind:
+			if kind != IfStepKind && !inChain {
+				*errs = append(*errs, NewDefinitionError(step.Line, "elseif and else must follow if or elseif"))
+			}
+			if (step.Do != nil) == (step.SubPath != nil) {
+				*errs = append(*errs, NewDefinitionError(step.Line, "exactly one of do and subpath is expected"))
+			}
+		case FinallyStepKind:
+			if (step.Do != nil) == (step.SubPath != nil) {
+				*errs = append(*errs, NewDefinitionError(step.Line, "exactly one of do and subpath is expected"))
+			}
+		case ForStepKind:
+			if step.Do == nil || step.SubPath != nil {
+				*errs = append(*errs, NewDefinitionError(step.Line, "for expects do"))
+			}
+		case DoStepKind:
+			if step.Do == nil || step.SubPath != nil {
+				*errs = append(*errs, NewDefinitionError(step.Line, "step must be one of prepare, if, elseif, else, finally, for, parallel and do"))
+			}
+		default:
+			if step.Do != nil || step.SubPath != nil {
+				*errs = append(*errs, NewDefinitionError(step.Line, "do and subpath are only allowed with if, elseif, else, finally and for"))
+			}
+		}
+		inChain = kind == IfStepKind || kind == ElseIfStepKind
+		checkSteps(step.SubPath, errs)
+	}
+}
+
+// Validate checks the structure of the definition and that every name it uses is registered
+func (d *FlowDefinition) Validate(registry *Registry) error {
+	var errs DefinitionErrors
+	checkSteps(d.Steps, &errs)
+	validateSteps(d.Steps, registry, &errs)
+	return errs.orNil()
+}
+
+func validateSteps(steps []*StepDefinition, registry *Registry, errs *DefinitionErrors) {
+	for _, step := range steps {
+		for _, name := range step.Prepare {
+			if _, ok := registry.GetPrepare(name); !ok {
+				*errs = append(*errs, NewDefinitionError(step.Line, "prepare function %q is not registered", name))
+			}
+		}
+		for _, name := range []string{step.If, step.ElseIf} {
+			if _, ok := registry.GetCondition(name); name != "" && !ok {
+				*errs = append(*errs, NewDefinitionError(step.Line, "condition %q is not registered", name))
+			}
+		}
+		if _, ok := registry.GetCallable(step.Compensate); step.Compensate != "" && !ok {
+			*errs = append(*errs, NewDefinitionError(step.Line, "callable %q is not registered", step.Compensate))
+		}
+		for _, names := range [][]string{step.Do, step.Parallel} {
+			for _, name := range names {
+				if _, ok := registry.GetCallable(name); !ok {
+					*errs = append(*errs, NewDefinitionError(step.Line, "callable %q is not registered", name))
+				}
+			}
+		}
+		validateSteps(step.SubPath, registry, errs)
+	}
+}
+
+// Build validates the definition and creates a new flow from it. The input is passed to every Prepare step.
+func (d *FlowDefinition) Build(registry *Registry, input _PrepareInput) (*Flow, error) {
+	if err := d.Validate(registry); err != nil {
+		return nil, err
+	}
+	return buildSteps(d.Steps, registry, input), nil
+}
+
+func buildSteps(steps []*StepDefinition, registry *Registry, input _PrepareInput) *Flow {
+	flow := NewFlow().SetRegistry(registry)
+	var chain *ElseFlowEngine
+	for _, step := range steps {
+		switch step.Kind() {
+		case PrepareStepKind:
+			flow.PrepareNamed(input, step.Prepare...)
+		case IfStepKind:
+			if step.SubPath != nil {
+				chain = flow.IfSubPathNamed(step.If, buildSteps(step.SubPath, registry, input))
+			} else {
+				chain = flow.IfNamed(step.If, step.Do...)
+			}
+		case ElseIfStepKind:
+			if step.SubPath != nil {
+				chain.ElseIfSubPathNamed(step.ElseIf, buildSteps(step.SubPath, registry, input))
+			} else {
+				chain.ElseIfNamed(step.ElseIf, step.Do...)
+			}
+		case ElseStepKind:
+			if step.SubPath != nil {
+				chain.ElseSubPath(buildSteps(step.SubPath, registry, input))
+			} else {
+				chain.ElseNamed(step.Do...)
+			}
+		case FinallyStepKind:
+			if step.SubPath != nil {
+				flow.FinallySubPath(buildSteps(step.SubPath, registry, input))
+			} else {
+				flow.FinallyNamed(step.Do...)
+			}
+		case ForStepKind:
+			flow.ForNamed(*step.For, step.Do...)
+		case ParallelStepKind:
+			flow.ParallelNamed(step.Parallel...)
+		default:
+			flow.DoNamed(step.Do...)
+		}
+		if step.Note != "" {
+			flow.SetNote(step.Note)
+		}
+		if step.Compensate != "" {
+			flow.CompensateNamed(step.Compensate)
+		}
+	}
+	return flow
+}
+
+// LoadFlow parses a YAML or JSON document and builds a flow from it
+func LoadFlow(document []byte, registry *Registry, input _PrepareInput) (*Flow, error) {
+	definition, err := ParseFlowDefinition(document)
+	if err != nil {
+		return nil, err
+	}
+	return definition.Build(registry, input)
+}
+
+// NewFlowDefinition describes an existing flow with the names its nodes were built with, such as by DoNamed or by
+// LoadFlow. Every functor, condition and prepare function of the flow must be given by a name registered in the
+// registry. The input of Prepare is not part of the definition.
+func NewFlowDefinition(engine IFlowEngine, registry *Registry) (*FlowDefinition, error) {
+	var errs DefinitionErrors
+	definition := &FlowDefinition{Steps: describeSteps(engine, registry, &errs)}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	return definition, nil
+}
+
+func describeSteps(engine IFlowEngine, registry *Registry, errs *DefinitionErrors) []*StepDefinition {
+	nodes := engine.getNodes()
+	steps := make([]*StepDefinition, 0, len(nodes))
+	for _, node := range nodes {
+		step := &StepDefinition{Note: node.GetNote()}
+		names := node.getNames()
+		if node.GetCompensation() != nil {
+			step.Compensate = describeName(names.compensation, node.GetCompensation(), "callable", registry, errs)
+		}
+		switch n := node.(type) {
+		case *PrepareNode:
+			step.Prepare = make([]string, 0, len(n.Functors))
+			for index, functor := range n.Functors {
+				step.Prepare = append(step.Prepare, describeName(names.functor(index), functor, "prepare function", registry, errs))
+			}
+		case *NormalNode:
+			step.Do = describeNames(names, n.Functors, registry, errs)
+		case *ForNode:
+			times := n.Times
+			step.For, step.Do = &times, describeNames(names, n.Functors, registry, errs)
+		case *ParallelNode:
+			step.Parallel = describeNames(names, n.Functors, registry, errs)
+		case *IfNode:
+			step.If = describeName(names.condition, n.Condition, "condition", registry, errs)
+			step.Do = describeNames(names, n.Functors, registry, errs)
+		case *ElseIfNode:
+			step.ElseIf = describeName(names.condition, n.Condition, "condition", registry, errs)
+			step.Do = describeNames(names, n.Functors, registry, errs)
+		case *ElseNode:
+			step.Else, step.Do = true, describeNames(names, n.Functors, registry, errs)
+		case *IfSubPathNode:
+			step.If = describeName(names.condition, n.Condition, "condition", registry, errs)
+			step.SubPath = describeSteps(n.SubPath, registry, errs)
+		case *ElseIfSubPathNode:
+			step.ElseIf = describeName(names.condition, n.Condition, "condition", registry, errs)
+			step.SubPath = describeSteps(n.SubPath, registry, errs)
+		case *ElseSubPathNode:
+			step.Else, step.SubPath = true, describeSteps(n.SubPath, registry, errs)
+		case *FinallyNode:
+			step.Finally, step.Do = true, describeNames(names, n.Functors, registry, errs)
+		case *FinallySubPathNode:
+			step.Finally, step.SubPath = true, describeSteps(n.SubPath, registry, errs)
+		default:
+			*errs = append(*errs, NewDefinitionError(0, "node %T can not be described", node))
+			continue
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func describeNames(names *nodeNames, functors []ICallable, registry *Registry, errs *DefinitionErrors) []string {
+	res := make([]string, 0, len(functors))
+	for index, functor := range functors {
+		res = append(res, describeName(names.functor(index), functor, "callable", registry, errs))
+	}
+	return res
+}
+
+// describeName checks that the function was given by a name which is registered as kind
+func describeName(name string, function interface{}, kind string, registry *Registry, errs *DefinitionErrors) string {
+	if name == "" {
+		*errs = append(*errs, NewDefinitionError(0, "%s %s is not given by name", kind, functionName(function)))
+		return functionName(function)
+	}
+	registered := false
+	switch kind {
+	case "callable":
+		_, registered = registry.GetCallable(name)
+	case "condition":
+		_, registered = registry.GetCondition(name)
+	case "prepare function":
+		_, registered = registry.GetPrepare(name)
+	}
+	if !registered {
+		*errs = append(*errs, NewDefinitionError(0, "%s %q is not registered", kind, name))
+	}
+	return name
+}
+
+func (d *FlowDefinition) ToYAML() ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(d); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (d *FlowDefinition) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
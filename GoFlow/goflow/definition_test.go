@@ -0,0 +1,149 @@
+package goflow
+
+import "testing"
+
+const orderDefinition = `steps:
+  - prepare: [load]
+  - do: [first, second]
+    note: check the stock
+  - if: is-vip
+    do: [vip]
+  - elseif: is-member
+    subpath:
+      - do: [member]
+  - else: true
+    do: [other]
+  - for: 2
+    do: [first]
+  - parallel: [second]
+  - finally: true
+    do: [last]
+`
+
+func newDefinitionRegistry(t *testing.T) *Registry {
+	t.Helper()
+	registry := NewRegistry()
+	errs := []error{
+		registry.RegisterPrepare("load", func(_data *_Data, input _PrepareInput) *_Result {
+			_data.FunctionName += "load;"
+			return nil
+		}),
+		registry.RegisterCallable("first", mark("first")),
+		registry.RegisterCallable("second", mark("second")),
+		registry.RegisterCallable("vip", mark("vip")),
+		registry.RegisterCallable("member", mark("member")),
+		registry.RegisterCallable("other", mark("other")),
+		registry.RegisterCallable("last", mark("last")),
+		registry.RegisterCondition("is-vip", never),
+		registry.RegisterCondition("is-member", always),
+	}
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return registry
+}
+
+func TestParseFlowDefinitionReadsEveryKind(t *testing.T) {
+	definition, err := ParseFlowDefinition([]byte(orderDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []StepKind{PrepareStepKind, DoStepKind, IfStepKind, ElseIfStepKind, ElseStepKind, ForStepKind,
+		ParallelStepKind, FinallyStepKind}
+	if len(definition.Steps) != len(expected) {
+		t.Fatalf("expected %d steps, got %d", len(expected), len(definition.Steps))
+	}
+	for index, kind := range expected {
+		if actual := definition.Steps[index].Kind(); actual != kind {
+			t.Errorf("step %d: expected kind %d, got %d", index, kind, actual)
+		}
+	}
+	if step := definition.Steps[1]; step.Line != 3 || step.Note != "check the stock" || len(step.Do) != 2 {
+		t.Errorf("unexpected step %+v", step)
+	}
+	if step := definition.Steps[3]; len(step.SubPath) != 1 || step.SubPath[0].Line != 9 {
+		t.Errorf("unexpected sub-path %+v", step.SubPath)
+	}
+}
+
+func TestParseFlowDefinitionReportsErrorsWithLines(t *testing.T) {
+	_, err := ParseFlowDefinition([]byte(`steps:
+  - do: [first]
+    unknown: 1
+  - else: true
+    do: [other]
+  - for: -1
+    do: [first]
+  - if: is-vip
+    parallel: [first]
+`))
+	errs, ok := err.(DefinitionErrors)
+	if !ok {
+		t.Fatalf("expected DefinitionErrors, got %v", err)
+	}
+	assertContains(t, errs.Error(),
+		`line 3: unknown key "unknown"`,
+		"line 4: elseif and else must follow if or elseif",
+		"line 6: for must be a non-negative integer",
+		"line 8: step can only be one of if, parallel",
+	)
+}
+
+func TestParseFlowDefinitionReadsJSON(t *testing.T) {
+	definition, err := ParseFlowDefinition([]byte("{\n\t\"steps\": [\n\t\t{\"do\": \"first\"},\n\t\t{\"for\": 2}\n\t]\n}"))
+	if err == nil {
+		t.Fatalf("expected an error, got %+v", definition)
+	}
+	assertContains(t, err.Error(), "line 4: for expects do")
+
+	definition, err = ParseFlowDefinition([]byte(`{"steps": [{"do": "first"}, {"parallel": ["first", "second"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(definition.Steps) != 2 || definition.Steps[0].Do[0] != "first" || len(definition.Steps[1].Parallel) != 2 {
+		t.Errorf("unexpected definition %+v", definition.Steps)
+	}
+}
+
+func TestParseFlowDefinitionRejectsEmptyDocument(t *testing.T) {
+	for _, document := range []string{"", "steps: 1", "- do: [first]"} {
+		if _, err := ParseFlowDefinition([]byte(document)); err == nil {
+			t.Errorf("expected %q to be rejected", document)
+		}
+	}
+}
+
+func TestValidateFindsUnregisteredNames(t *testing.T) {
+	definition, err := ParseFlowDefinition([]byte("steps:\n  - if: missing\n    do: [first, absent]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = definition.Validate(newDefinitionRegistry(t))
+	if err == nil {
+		t.Fatal("expected the names to be unknown")
+	}
+	assertContains(t, err.Error(), `line 2: condition "missing" is not registered`,
+		`line 2: callable "absent" is not registered`)
+	if _, err := definition.Build(newDefinitionRegistry(t), _PrepareInput{}); err == nil {
+		t.Error("expected Build to validate the definition")
+	}
+}
+
+func TestLoadFlowRunsDefinition(t *testing.T) {
+	flow, err := LoadFlow([]byte(orderDefinition), newDefinitionRegistry(t), _PrepareInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := flow.Wait(); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	expected := "load;first;second;member;first;first;second;last;"
+	if actual := flow.getData().FunctionName; actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+	if note := flow.getNodes()[1].GetNote(); note != "check the stock" {
+		t.Errorf("expected the note to be set, got %q", note)
+	}
+}
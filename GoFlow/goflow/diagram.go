@@ -0,0 +1,480 @@
+package goflow
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+type vertexShape int64
+
+const (
+	terminalShape vertexShape = iota
+	taskShape
+	decisionShape
+	forkShape
+	joinShape
+)
+
+type edgeBranch int64
+
+const (
+	noBranch edgeBranch = iota
+	trueBranch
+	falseBranch
+)
+
+type diagramVertex struct {
+	id    string
+	label string
+	shape vertexShape
+	node  IBasicFlowNode
+	// body is set for the functors of a conditional node, which only run if the branch is taken
+	body bool
+}
+
+type diagramEdge struct {
+	from   *diagramVertex
+	to     *diagramVertex
+	label  string
+	branch edgeBranch
+}
+
+type diagramGroup struct {
+	id       string
+	label    string
+	vertices []*diagramVertex
+	groups   []*diagramGroup
+}
+
+// diagramExit is a dangling edge which will be connected to the next vertex of the flow
+type diagramExit struct {
+	from   *diagramVertex
+	label  string
+	branch edgeBranch
+}
+
+// diagram is the format-independent description of a flow shared by ToDOT and the other exporters
+type diagram struct {
+	root  *diagramGroup
+	edges []*diagramEdge
+	count int
+}
+
+func newDiagram(engine IFlowEngine) *diagram {
+	d := &diagram{root: &diagramGroup{}}
+	start := d.addVertex(d.root, "Start", terminalShape, nil, false)
+	exits := d.addFlow(d.root, engine, []diagramExit{{from: start}})
+	end := d.addVertex(d.root, "End", terminalShape, nil, false)
+	d.connect(exits, end)
+	return d
+}
+
+func (d *diagram) addVertex(group *diagramGroup, label string, shape vertexShape, node IBasicFlowNode, body bool) *diagramVertex {
+	vertex := &diagramVertex{
+		id:    fmt.Sprintf("n%d", d.count),
+		label: label,
+		shape: shape,
+		node:  node,
+		body:  body,
+	}
+	d.count++
+	group.vertices = append(group.vertices, vertex)
+	return vertex
+}
+
+func (d *diagram) addGroup(parent *diagramGroup, label string) *diagramGroup {
+	group := &diagramGroup{id: fmt.Sprintf("cluster_%d", d.count), label: label}
+	d.count++
+	parent.groups = append(parent.groups, group)
+	return group
+}
+
+func (d *diagram) connect(exits []diagramExit, to *diagramVertex) {
+	for _, exit := range exits {
+		d.edges = append(d.edges, &diagramEdge{from: exit.from, to: to, label: exit.label, branch: exit.branch})
+	}
+}
+
+func (d *diagram) addFlow(group *diagramGroup, engine IFlowEngine, entries []diagramExit) []diagramExit {
+	if engine == nil {
+		return entries
+	}
+	nodes := engine.getNodes()
+	exits := entries
+	for i := 0; i < len(nodes); i++ {
+		if !isBranchHead(nodes[i]) {
+			exits = d.addNode(group, nodes[i], exits)
+			continue
+		}
+
+		// An If chain: every taken branch leaves the chain, the last false edge falls through to the next node
+		var chainExits []diagramExit
+		pending := exits
+		for j := i; j < len(nodes); j++ {
+			if j > i && !isBranchTail(nodes[j]) {
+				break
+			}
+			i = j
+			var taken []diagramExit
+			taken, pending = d.addBranch(group, nodes[j], pending)
+			chainExits = append(chainExits, taken...)
+		}
+		exits = append(chainExits, pending...)
+	}
+	return exits
+}
+
+// addBranch adds one branch of an If chain and returns the exits of the taken branch and the exits when it is not taken
+func (d *diagram) addBranch(group *diagramGroup, node IBasicFlowNode, entries []diagramExit) ([]diagramExit, []diagramExit) {
+	var condition IBoolFunc
+	var functors []ICallable
+	var subPath IFlowEngine
+	kind := ""
+	switch n := node.(type) {
+	case *IfNode:
+		kind, condition, functors = "If", n.Condition, n.Functors
+	case *ElseIfNode:
+		kind, condition, functors = "ElseIf", n.Condition, n.Functors
+	case *ElseNode:
+		kind, functors = "Else", n.Functors
+	case *IfSubPathNode:
+		kind, condition, subPath = "IfSubPath", n.Condition, n.SubPath
+	case *ElseIfSubPathNode:
+		kind, condition, subPath = "ElseIfSubPath", n.Condition, n.SubPath
+	case *ElseSubPathNode:
+		kind, subPath = "ElseSubPath", n.SubPath
+	}
+
+	var bodyEntries, notTaken []diagramExit
+	if node.GetNodeType() == ElseNodeType || node.GetNodeType() == ElseSubPathNodeType {
+		bodyEntries = entries
+	} else {
+		decision := d.addVertex(group, labelWithNote(kind+" "+conditionName(node, condition)+"?", node.GetNote()), decisionShape, node, false)
+		d.connect(entries, decision)
+		bodyEntries = []diagramExit{{from: decision, label: "true", branch: trueBranch}}
+		notTaken = []diagramExit{{from: decision, label: "false", branch: falseBranch}}
+	}
+
+	if subPath == nil {
+		body := d.addVertex(group, labelWithNote(functorLabel("Do", functorNames(node, functors)), noteOfElse(node)), taskShape, node, true)
+		d.connect(bodyEntries, body)
+		return []diagramExit{{from: body}}, notTaken
+	}
+	if len(subPath.getNodes()) == 0 {
+		return bodyEntries, notTaken
+	}
+	cluster := d.addGroup(group, labelWithNote(kind, noteOfElse(node)))
+	return d.addFlow(cluster, subPath, bodyEntries), notTaken
+}
+
+func (d *diagram) addNode(group *diagramGroup, node IBasicFlowNode, entries []diagramExit) []diagramExit {
+	switch n := node.(type) {
+	case *NormalNode:
+		return d.addTask(group, node, labelWithNote(functorLabel("Do", functorNames(n, n.Functors)), n.Note), entries)
+	case *PrepareNode:
+		return d.addTask(group, node, labelWithNote(functorLabel("Prepare", prepareNames(n, n.Functors)), n.Note), entries)
+	case *ForNode:
+		vertex := d.addVertex(group, labelWithNote(functorLabel(fmt.Sprintf("For %d times", n.Times), functorNames(n, n.Functors)), n.Note), taskShape, node, false)
+		d.connect(entries, vertex)
+		d.connect([]diagramExit{{from: vertex, label: fmt.Sprintf("x%d", n.Times)}}, vertex)
+		return []diagramExit{{from: vertex}}
+	case *ParallelNode:
+		return d.addFork(group, node, labelWithNote("Parallel", n.Note), d.functorBranches(group, node, functorNames(n, n.Functors)), entries)
+	case *RaceNode:
+		return d.addFork(group, node, labelWithNote("Race", n.Note), d.functorBranches(group, node, functorNames(n, n.Functors)), entries)
+	case *QuorumNode:
+		label := labelWithNote(fmt.Sprintf("Quorum %d of %d", n.Needed, len(n.Functors)), n.Note)
+		return d.addFork(group, node, label, d.functorBranches(group, node, functorNames(n, n.Functors)), entries)
+	case *RaceSubPathNode:
+		branches := make([]diagramBranch, 0, len(n.SubPaths))
+		for _, subPath := range n.SubPaths {
+			subPath := subPath
+			branches = append(branches, func(entries []diagramExit) []diagramExit {
+				if subPath == nil || len(subPath.getNodes()) == 0 {
+					return entries
+				}
+				return d.addFlow(d.addGroup(group, "RaceSubPath"), subPath, entries)
+			})
+		}
+		return d.addFork(group, node, labelWithNote("RaceSubPath", n.Note), branches, entries)
+	case *FinallyNode:
+		return d.addTask(group, node, labelWithNote(functorLabel("Finally", functorNames(n, n.Functors)), n.Note), entries)
+	case *FinallySubPathNode:
+		if n.SubPath == nil || len(n.SubPath.getNodes()) == 0 {
+			return entries
+		}
+		return d.addFlow(d.addGroup(group, labelWithNote("FinallySubPath", n.Note)), n.SubPath, entries)
+	case *TrySubPathNode:
+		if n.SubPath == nil || len(n.SubPath.getNodes()) == 0 {
+			return entries
+		}
+		return d.addFlow(d.addGroup(group, labelWithNote("TrySubPath", n.Note)), n.SubPath, entries)
+	case *SignalNode:
+		return d.addTask(group, node, labelWithNote("WaitForSignal\n"+n.Name, n.Note), entries)
+	case *CatchNode:
+		return d.addTask(group, node, labelWithNote("Catch\n"+functionName(n.Handler), n.Note), entries)
+	}
+	if isBranchHead(node) || isBranchTail(node) {
+		taken, notTaken := d.addBranch(group, node, entries)
+		return append(taken, notTaken...)
+	}
+	return d.addTask(group, node, labelWithNote(fmt.Sprintf("%T", node), node.GetNote()), entries)
+}
+
+// diagramBranch adds one of the branches of a fork from the exit of the fork and returns the exits of the branch
+type diagramBranch func(entries []diagramExit) []diagramExit
+
+// addFork adds a node whose branches run concurrently, as a fork to every branch and a join after them
+func (d *diagram) addFork(group *diagramGroup, node IBasicFlowNode, label string, branches []diagramBranch, entries []diagramExit) []diagramExit {
+	fork := d.addVertex(group, label, forkShape, node, false)
+	d.connect(entries, fork)
+	join := d.addVertex(group, "", joinShape, node, false)
+	if len(branches) == 0 {
+		d.connect([]diagramExit{{from: fork}}, join)
+	}
+	for _, branch := range branches {
+		d.connect(branch([]diagramExit{{from: fork}}), join)
+	}
+	return []diagramExit{{from: join}}
+}
+
+// functorBranches makes a branch of a single task for every functor
+func (d *diagram) functorBranches(group *diagramGroup, node IBasicFlowNode, names []string) []diagramBranch {
+	branches := make([]diagramBranch, 0, len(names))
+	for _, name := range names {
+		name := name
+		branches = append(branches, func(entries []diagramExit) []diagramExit {
+			return d.addTask(group, node, name, entries)
+		})
+	}
+	return branches
+}
+
+func (d *diagram) addTask(group *diagramGroup, node IBasicFlowNode, label string, entries []diagramExit) []diagramExit {
+	vertex := d.addVertex(group, label, taskShape, node, false)
+	d.connect(entries, vertex)
+	return []diagramExit{{from: vertex}}
+}
+
+// highlight tells whether the vertex ran successfully, failed or did not run according to the report
+func (v *diagramVertex) highlight(report *ExecutionReport) NodeState {
+	if v.node == nil || report == nil {
+		return NotRunNodeState
+	}
+	state := report.GetState(v.node)
+	switch {
+	case state == FailedNodeState:
+		return FailedNodeState
+	case state == SucceededNodeState || state == ReturnedNodeState || state == CachedNodeState:
+		return SucceededNodeState
+	case state == NotTakenNodeState && v.shape == decisionShape:
+		return SucceededNodeState
+	}
+	return NotRunNodeState
+}
+
+func (e *diagramEdge) taken(report *ExecutionReport) bool {
+	if report == nil || e.to.highlight(report) == NotRunNodeState {
+		return false
+	}
+	switch e.branch {
+	case trueBranch:
+		state := report.GetState(e.from.node)
+		return state == SucceededNodeState || state == FailedNodeState || state == ReturnedNodeState
+	case falseBranch:
+		return report.GetState(e.from.node) == NotTakenNodeState
+	}
+	return e.from.node == nil || e.from.highlight(report) != NotRunNodeState
+}
+
+func isBranchHead(node IBasicFlowNode) bool {
+	return node.GetNodeType() == IfNodeType || node.GetNodeType() == IfSubPathNodeType
+}
+
+func isBranchTail(node IBasicFlowNode) bool {
+	switch node.GetNodeType() {
+	case ElseIfNodeType, ElseNodeType, ElseIfSubPathNodeType, ElseSubPathNodeType:
+		return true
+	}
+	return false
+}
+
+// noteOfElse returns the note of a node which has no decision vertex to carry it
+func noteOfElse(node IBasicFlowNode) string {
+	if node.GetNodeType() == ElseNodeType || node.GetNodeType() == ElseSubPathNodeType {
+		return node.GetNote()
+	}
+	return ""
+}
+
+func functorLabel(kind string, names []string) string {
+	return strings.Join(append([]string{kind}, names...), "\n")
+}
+
+func labelWithNote(label string, note string) string {
+	if note == "" {
+		return label
+	}
+	return label + "\n(" + note + ")"
+}
+
+// functionName resolves the name of a function value, such as "main.SimpleFunc1", by its program counter
+func functionName(function interface{}) string {
+	value := reflect.ValueOf(function)
+	if !value.IsValid() || value.Kind() != reflect.Func || value.IsNil() {
+		return "nil"
+	}
+	runtimeFunc := runtime.FuncForPC(value.Pointer())
+	if runtimeFunc == nil {
+		return "unknown"
+	}
+	name := runtimeFunc.Name()
+	if index := strings.LastIndex(name, "/"); index >= 0 {
+		name = name[index+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+func (f *FlowEngine) ToDOT() string {
+	return newDiagram(f).dot(nil)
+}
+
+// ToDOTWithReport renders the flow like ToDOT and highlights the nodes and edges which were taken in the report
+func (f *FlowEngine) ToDOTWithReport(report *ExecutionReport) string {
+	return newDiagram(f).dot(report)
+}
+
+func (e *ElseFlowEngine) ToDOT() string {
+	return newDiagram(e).dot(nil)
+}
+
+func (e *ElseFlowEngine) ToDOTWithReport(report *ExecutionReport) string {
+	return newDiagram(e).dot(report)
+}
+
+func (d *diagram) dot(report *ExecutionReport) string {
+	builder := &strings.Builder{}
+	builder.WriteString("digraph flow {\n")
+	builder.WriteString("\tnode [fontname=\"Helvetica\"];\n")
+	builder.WriteString("\tedge [fontname=\"Helvetica\"];\n")
+	d.dotGroup(builder, d.root, report, "\t")
+	for _, edge := range d.edges {
+		attributes := make([]string, 0, 3)
+		if edge.label != "" {
+			attributes = append(attributes, "label="+dotQuote(edge.label))
+		}
+		if edge.taken(report) {
+			attributes = append(attributes, "penwidth=2", "color=\"darkgreen\"")
+		}
+		builder.WriteString(fmt.Sprintf("\t%s -> %s", edge.from.id, edge.to.id))
+		if len(attributes) != 0 {
+			builder.WriteString(" [" + strings.Join(attributes, ", ") + "]")
+		}
+		builder.WriteString(";\n")
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+func (d *diagram) dotGroup(builder *strings.Builder, group *diagramGroup, report *ExecutionReport, indent string) {
+	for _, vertex := range group.vertices {
+		attributes := []string{"label=" + dotQuote(vertex.label), "shape=" + dotShape(vertex.shape)}
+		if report != nil && vertex.node != nil {
+			switch vertex.highlight(report) {
+			case SucceededNodeState:
+				attributes = append(attributes, "style=filled", "fillcolor=\"palegreen\"")
+			case FailedNodeState:
+				attributes = append(attributes, "style=filled", "fillcolor=\"salmon\"")
+			default:
+				attributes = append(attributes, "style=dashed", "color=\"gray\"")
+			}
+		}
+		builder.WriteString(fmt.Sprintf("%s%s [%s];\n", indent, vertex.id, strings.Join(attributes, ", ")))
+	}
+	for _, sub := range group.groups {
+		builder.WriteString(fmt.Sprintf("%ssubgraph %s {\n", indent, sub.id))
+		builder.WriteString(fmt.Sprintf("%s\tlabel=%s;\n", indent, dotQuote(sub.label)))
+		builder.WriteString(fmt.Sprintf("%s\tstyle=rounded;\n", indent))
+		d.dotGroup(builder, sub, report, indent+"\t")
+		builder.WriteString(indent + "}\n")
+	}
+}
+
+func dotShape(shape vertexShape) string {
+	switch shape {
+	case terminalShape:
+		return "oval"
+	case decisionShape:
+		return "diamond"
+	case forkShape:
+		return "trapezium"
+	case joinShape:
+		return "invtrapezium"
+	}
+	return "box"
+}
+
+func dotQuote(text string) string {
+	text = strings.ReplaceAll(text, "\\", "\\\\")
+	text = strings.ReplaceAll(text, "\"", "\\\"")
+	text = strings.ReplaceAll(text, "\n", "\\n")
+	return "\"" + text + "\""
+}
+
+func (f *FlowEngine) ToMermaid() string {
+	return newDiagram(f).mermaid()
+}
+
+func (e *ElseFlowEngine) ToMermaid() string {
+	return newDiagram(e).mermaid()
+}
+
+func (d *diagram) mermaid() string {
+	builder := &strings.Builder{}
+	builder.WriteString("flowchart TD\n")
+	d.mermaidGroup(builder, d.root, "    ")
+	for _, edge := range d.edges {
+		if edge.label != "" {
+			builder.WriteString(fmt.Sprintf("    %s -->|%s| %s\n", edge.from.id, mermaidQuote(edge.label), edge.to.id))
+		} else {
+			builder.WriteString(fmt.Sprintf("    %s --> %s\n", edge.from.id, edge.to.id))
+		}
+	}
+	return builder.String()
+}
+
+func (d *diagram) mermaidGroup(builder *strings.Builder, group *diagramGroup, indent string) {
+	for _, vertex := range group.vertices {
+		builder.WriteString(indent + vertex.id + mermaidShape(vertex.shape, mermaidQuote(vertex.label)) + "\n")
+	}
+	for _, sub := range group.groups {
+		builder.WriteString(fmt.Sprintf("%ssubgraph %s [%s]\n", indent, sub.id, mermaidQuote(sub.label)))
+		d.mermaidGroup(builder, sub, indent+"    ")
+		builder.WriteString(indent + "end\n")
+	}
+}
+
+func mermaidShape(shape vertexShape, label string) string {
+	switch shape {
+	case terminalShape:
+		return "([" + label + "])"
+	case decisionShape:
+		return "{" + label + "}"
+	case forkShape:
+		return "[/" + label + "\\]"
+	case joinShape:
+		return "[\\" + label + "/]"
+	}
+	return "[" + label + "]"
+}
+
+func mermaidQuote(text string) string {
+	if text == "" {
+		text = " "
+	}
+	text = strings.ReplaceAll(text, "\"", "#quot;")
+	text = strings.ReplaceAll(text, "\n", "<br/>")
+	return "\"" + text + "\""
+}
@@ -0,0 +1,90 @@
+package goflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToDOTRendersNodesInOrder(t *testing.T) {
+	flow := NewFlow().Do(succeed).If(always, succeed).Else(fail).Parallel(succeed, fail)
+	dot := flow.ToDOT()
+
+	assertContains(t, dot,
+		"digraph flow {",
+		`label="Start", shape=oval`,
+		`label="Do\ngoflow.succeed", shape=box`,
+		`label="If goflow.always?", shape=diamond`,
+		`label="Parallel", shape=trapezium`,
+		`label="", shape=invtrapezium`,
+		`label="End", shape=oval`,
+		`[label="true"]`,
+		`[label="false"]`,
+	)
+	if !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("the graph is not closed:\n%s", dot)
+	}
+}
+
+func TestToDOTForksEveryConcurrentNode(t *testing.T) {
+	flow := NewFlow().Race(succeed, fail).Quorum(1, succeed, fail).
+		RaceSubPath(NewFlow().Do(succeed), NewFlow())
+	dot := flow.ToDOT()
+
+	assertContains(t, dot, `label="Race", shape=trapezium`, `label="Quorum 1 of 2", shape=trapezium`,
+		`label="RaceSubPath", shape=trapezium`, "subgraph cluster_")
+	if count := strings.Count(dot, "shape=invtrapezium"); count != 3 {
+		t.Errorf("expected 3 joins, got %d:\n%s", count, dot)
+	}
+	// Two functors of each node go from the fork and to the join, the empty sub-path goes straight to the join
+	if count := strings.Count(dot, "->"); count != 15 {
+		t.Errorf("expected 15 edges, got %d:\n%s", count, dot)
+	}
+}
+
+func TestToDOTWithReportHighlightsTakenPath(t *testing.T) {
+	flow := NewFlow().If(never, succeed).Else(succeed).Do(fail).Do(succeed)
+	flow.Wait()
+	dot := flow.ToDOTWithReport(flow.Report())
+
+	if count := strings.Count(dot, `fillcolor="palegreen"`); count != 2 {
+		t.Errorf("expected the decision and the Else to succeed, got %d:\n%s", count, dot)
+	}
+	if count := strings.Count(dot, `fillcolor="salmon"`); count != 1 {
+		t.Errorf("expected one failed node, got %d:\n%s", count, dot)
+	}
+	assertContains(t, dot, `style=dashed, color="gray"`, `[label="false", penwidth=2, color="darkgreen"]`)
+}
+
+func TestToDOTQuotesLabels(t *testing.T) {
+	flow := NewFlow().Do(succeed).SetNote(`say "hi"`)
+	assertContains(t, flow.ToDOT(), `label="Do\ngoflow.succeed\n(say \"hi\")"`)
+}
+
+func TestToMermaidRendersFlowchart(t *testing.T) {
+	flow := NewFlow().Do(succeed).IfSubPath(always, NewFlow().Do(fail)).Else(succeed).Parallel(succeed)
+	mermaid := flow.ToMermaid()
+
+	if !strings.HasPrefix(mermaid, "flowchart TD\n") {
+		t.Errorf("expected a top-down flowchart:\n%s", mermaid)
+	}
+	assertContains(t, mermaid,
+		`n0(["Start"])`,
+		`["Do<br/>goflow.succeed"]`,
+		`{"IfSubPath goflow.always?"}`,
+		`subgraph cluster_`, `["IfSubPath"]`,
+		`[/"Parallel"\]`,
+		`[\" "/]`,
+		`-->|"true"|`,
+		`-->|"false"|`,
+		`(["End"])`,
+	)
+	if strings.Count(mermaid, "subgraph ") != strings.Count(mermaid, "    end\n") {
+		t.Errorf("every subgraph must be ended:\n%s", mermaid)
+	}
+}
+
+func TestToMermaidQuotesLabels(t *testing.T) {
+	flow := NewFlow().Do(succeed).SetNote(`say "hi"`)
+	assertContains(t, NewFlow().ToMermaid(), `n0(["Start"])`, `n1(["End"])`, "n0 --> n1")
+	assertContains(t, flow.ToMermaid(), `["Do<br/>goflow.succeed<br/>(say #quot;hi#quot;)"]`)
+}
@@ -0,0 +1,41 @@
+package goflow
+
+import "testing"
+
+func TestFinallyRunsAfterFailure(t *testing.T) {
+	flow := NewFlow().Do(mark("first")).Do(fail).Do(mark("skipped")).
+		Finally(mark("finally")).FinallySubPath(NewFlow().Do(mark("cleanup")))
+
+	if result := flow.Wait(); result.Err != errTest {
+		t.Fatalf("expected the failure to be kept, got %v", result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "first;finally;cleanup;" {
+		t.Errorf("unexpected calls %s", data)
+	}
+	if state := flow.Report().GetState(flow.getNodes()[3]); state != SucceededNodeState {
+		t.Errorf("expected the Finally to succeed, got %s", state)
+	}
+}
+
+func TestFinallyFailureReplacesEarlierFailure(t *testing.T) {
+	flow := NewFlow().Do(fail).Finally(func(_data *_Data) *_Result {
+		return &_Result{StatusCode: 3}
+	}).Finally(mark("second"))
+
+	if result := flow.Wait(); result.Err != nil || result.StatusCode != 3 {
+		t.Errorf("expected the failure of the Finally, got %+v", result)
+	}
+	if data := flow.getData().FunctionName; data != "second;" {
+		t.Errorf("expected every Finally to run, got %s", data)
+	}
+}
+
+func TestFinallyFailsSuccessfulFlow(t *testing.T) {
+	flow := NewFlow().Do(succeed).Finally(fail).Do(mark("after"))
+	if result := flow.Wait(); result.Err != errTest {
+		t.Errorf("expected the failure of the Finally, got %v", result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "" {
+		t.Errorf("expected the nodes after the failure to be skipped, got %s", data)
+	}
+}
@@ -1,5 +1,6 @@
 import argparse
 import glob
+import os
 
 
 def main():
@@ -18,6 +19,9 @@ def main():
                         help="The directory of the generated files to put in")
     parser.add_argument("-p","--package",type=str, metavar="",
                         help="The package name of the output Golang source file")
+    parser.add_argument("-i", "--import", dest="import_path", type=str, metavar="",
+                        help="The import path of the generated package, the goflowtest package is generated in the "
+                             "goflowtest directory of the output if it is given")
 
     args = parser.parse_args()
 
@@ -27,7 +31,7 @@ def main():
         return
 
     # Find all the files
-    for name in ['go_flow', 'structure']:
+    for name in ['go_flow', 'structure', 'report', 'diagram', 'registry', 'definition', 'plan', 'checkpoint', 'compensation', 'catch', 'return', 'handle', 'dag', 'observer', 'ratelimit', 'circuit', 'cache', 'race', 'hedge', 'quorum', 'signal', 'clock', 'scheduler', 'coverage', 'recorder']:
         file = glob.glob(args.source + f"/{name}.go")
         if file:
             with open(f'{args.output}/{name}.go', 'w') as output:
@@ -41,6 +45,22 @@ def main():
                         lines.append(line)
                     output.writelines(lines)
 
+    # The goflowtest package imports the generated package, its files are only built once generated
+    if args.import_path is not None:
+        package = args.package if args.package is not None else "goflow"
+        os.makedirs(f'{args.output}/goflowtest', exist_ok=True)
+        for file in sorted(glob.glob(args.source + "/goflowtest/*.go")):
+            with open(f'{args.output}/goflowtest/{os.path.basename(file)}', 'w') as output:
+                with open(file, 'r') as source:
+                    lines = ["//generated by GoFlow, contact the author if you have question\n\n"]
+                    content = source.read().replace("//go:build goflowtest\n// +build goflowtest\n\n", "")
+                    for line in content.splitlines(keepends=True):
+                        line = line.replace('"goflow/goflow"', f'"{args.import_path}"').replace("goflow.", f"{package}.")
+                        line = line.replace("_Data", args.data).replace("_Result", args.result).replace("_PrepareInput",
+                                                                                                     args.prepare)
+                        lines.append(line)
+                    output.writelines(lines)
+
     # Print the message
     print("[SUCCESS]")
 
@@ -1,8 +1,10 @@
 package goflow
 
 import (
+	"context"
 	"runtime/debug"
 	"sync"
+	"time"
 )
 
 type ICallable = func(_data *_Data) *_Result
@@ -19,6 +21,12 @@ type IOnSuccessFunc = func(_data *_Data, _result *_Result)
 
 type IOnFailFunc = func(_data *_Data, _result *_Result)
 
+type ICatchMatcher = func(_result *_Result) bool
+
+type ICatchFunc = func(_data *_Data, _result *_Result) *_Result
+
+type ISignalFunc = func(_data *_Data, payload interface{}) *_Result
+
 type NodeType int64
 
 const (
@@ -31,6 +39,29 @@ const (
 	IfSubPathNodeType
 	ElseIfSubPathNodeType
 	ElseSubPathNodeType
+	FinallyNodeType
+	FinallySubPathNodeType
+	TrySubPathNodeType
+	CatchNodeType
+	RaceNodeType
+	RaceSubPathNodeType
+	QuorumNodeType
+	SignalNodeType
+)
+
+type NodeState int64
+
+const (
+	NotRunNodeState NodeState = iota
+	SkippedNodeState
+	RunningNodeState
+	NotTakenNodeState
+	SucceededNodeState
+	FailedNodeState
+	// ReturnedNodeState means a functor of the node returned Return, which ends the flow successfully
+	ReturnedNodeState
+	// CachedNodeState means the node did not run, its effect was taken from its cache instead
+	CachedNodeState
 )
 
 type IFlowEngine interface {
@@ -42,7 +73,13 @@ type IFlowEngine interface {
 	setOnFailFunc(function IOnFailFunc)
 	getOnSuccessFunc() IOnSuccessFunc
 	setOnSuccessFunc(function IOnSuccessFunc)
+	getFailHandlers() []*failHandler
+	setFailHandlers(handlers []*failHandler)
 	getNodes() []IBasicFlowNode
+	getRegistry() *Registry
+	setContext(ctx context.Context)
+	setClock(clock Clock)
+	setTape(tape tape, position string)
 	Attach(engine IFlowEngine)
 	Inherit(engine IFlowEngine)
 	Wait() *_Result
@@ -57,6 +94,18 @@ type IBasicFlowNode interface {
 	GetNext() IBasicFlowNode
 	GetNodeType() NodeType
 	SetShouldSkip(shouldSkip bool)
+	GetShouldSkip() bool
+	SetCompensation(functor ICallable)
+	GetCompensation() ICallable
+	SetRateLimit(limiter IRateLimiter)
+	SetCircuitBreaker(breaker *CircuitBreaker)
+	SetHedge(hedge *Hedge)
+	SetCache(cache *NodeCache)
+	GetCache() *NodeCache
+	SetRegistry(registry *Registry)
+	getNames() *nodeNames
+	SetClock(clock Clock)
+	setTape(tape tape, position string)
 	SetNote(note string)
 	GetNote() string
 	SetBeginLogger(logger INodeBeginLogger)
@@ -65,6 +114,8 @@ type IBasicFlowNode interface {
 	GetEndLogger() INodeEndLogger
 	SetData(data *_Data)
 	SetResultPtr(result **_Result)
+	GetState() NodeState
+	SetState(state NodeState)
 }
 
 type Flow = FlowEngine
@@ -77,13 +128,20 @@ func NewFlow() *Flow {
 
 //Errors
 
-type ConditionNotFoundError struct{}
+// ConditionNotFoundError is the failure of a conditional node without condition. Name is set if the condition was
+// given by a name which is not registered.
+type ConditionNotFoundError struct {
+	Name string
+}
 
 func NewConditionNotFoundError() *ConditionNotFoundError {
 	return &ConditionNotFoundError{}
 }
 
 func (c *ConditionNotFoundError) Error() string {
+	if c.Name != "" {
+		return "condition " + c.Name + " is not found"
+	}
 	return "condition is nil"
 }
 
@@ -99,18 +157,41 @@ func (c *PanicHappened) Error() string {
 	return c.Msg
 }
 
+type FunctorNotFoundError struct {
+	Name string
+}
+
+func NewFunctorNotFoundError(name string) *FunctorNotFoundError {
+	return &FunctorNotFoundError{Name: name}
+}
+
+func (f *FunctorNotFoundError) Error() string {
+	return "functor " + f.Name + " is not found"
+}
+
 //END Errors
 
 // BasicFlowNode Implementation
 type BasicFlowNode struct {
-	NodeType     NodeType
-	Next         IBasicFlowNode
-	Data         *_Data
-	ShouldSkip   bool
-	parentResult **_Result
-	BeginLogger  INodeBeginLogger
-	EndLogger    INodeEndLogger
-	Note         string
+	NodeType       NodeType
+	Next           IBasicFlowNode
+	Data           *_Data
+	ShouldSkip     bool
+	parentResult   **_Result
+	BeginLogger    INodeBeginLogger
+	EndLogger      INodeEndLogger
+	Note           string
+	State          NodeState
+	Compensation   ICallable
+	RateLimiter    IRateLimiter
+	CircuitBreaker *CircuitBreaker
+	Hedge          *Hedge
+	Cache          *NodeCache
+	registry       *Registry
+	clock          Clock
+	tape           tape
+	position       string
+	names          nodeNames
 }
 
 func NewBasicFlowNode(data *_Data, parentResult **_Result, nodeType NodeType) *BasicFlowNode {
@@ -131,16 +212,19 @@ func (b *BasicFlowNode) GetParentResult() *_Result {
 
 func (b *BasicFlowNode) Run() {
 	if b.ShouldSkip || b.GetParentResult().Err != nil || b.GetParentResult().StatusCode != 0 {
+		b.State = SkippedNodeState
 		return
 	}
 	if b.BeginLogger != nil {
 		b.BeginLogger(b.Note, b.Data)
 	}
 
+	b.State = RunningNodeState
 	result := b.ImplTask()
 	if result != nil {
 		b.SetParentResult(result)
 	}
+	b.finishState()
 
 	if b.EndLogger != nil {
 		b.EndLogger(b.Note, b.Data, b.GetParentResult())
@@ -171,6 +255,10 @@ func (b *BasicFlowNode) SetShouldSkip(shouldSkip bool) {
 	b.ShouldSkip = shouldSkip
 }
 
+func (b *BasicFlowNode) GetShouldSkip() bool {
+	return b.ShouldSkip
+}
+
 func (b *BasicFlowNode) SetNote(note string) {
 	b.Note = note
 }
@@ -203,6 +291,156 @@ func (b *BasicFlowNode) SetResultPtr(result **_Result) {
 	b.parentResult = result
 }
 
+func (b *BasicFlowNode) SetCompensation(functor ICallable) {
+	b.Compensation = functor
+	b.names.compensation = ""
+}
+
+func (b *BasicFlowNode) GetCompensation() ICallable {
+	return b.Compensation
+}
+
+func (b *BasicFlowNode) SetRateLimit(limiter IRateLimiter) {
+	b.RateLimiter = limiter
+}
+
+func (b *BasicFlowNode) SetCircuitBreaker(breaker *CircuitBreaker) {
+	b.CircuitBreaker = breaker
+}
+
+func (b *BasicFlowNode) SetHedge(hedge *Hedge) {
+	b.Hedge = hedge
+}
+
+func (b *BasicFlowNode) SetCache(cache *NodeCache) {
+	b.Cache = cache
+}
+
+func (b *BasicFlowNode) GetCache() *NodeCache {
+	return b.Cache
+}
+
+func (b *BasicFlowNode) SetRegistry(registry *Registry) {
+	b.registry = registry
+}
+
+func (b *BasicFlowNode) getNames() *nodeNames {
+	return &b.names
+}
+
+func (b *BasicFlowNode) SetClock(clock Clock) {
+	b.clock = clock
+}
+
+func (b *BasicFlowNode) getClock() Clock {
+	return clockOr(b.clock)
+}
+
+// setTape sets the tape of the flow and the position of the node in the flow, which the tape matches the calls by
+func (b *BasicFlowNode) setTape(tape tape, position string) {
+	b.tape, b.position = tape, position
+}
+
+func (b *BasicFlowNode) GetState() NodeState {
+	return b.State
+}
+
+func (b *BasicFlowNode) SetState(state NodeState) {
+	b.State = state
+}
+
+// call runs the functor at index of the node once the rate limits of the node and of the functor allow it, through the
+// circuit breakers of the functor and of the node
+func (b *BasicFlowNode) call(index int, functor ICallable) *_Result {
+	return b.invoke(b.Data, index, functor, nil, functor)
+}
+
+// callOn is call on other data than the one of the node, such as the copy of the data a branch of a Race runs on
+func (b *BasicFlowNode) callOn(data *_Data, index int, functor ICallable) *_Result {
+	return b.invoke(data, index, functor, nil, functor)
+}
+
+func (b *BasicFlowNode) callPrepare(index int, functor IPrepareFunc, input _PrepareInput) *_Result {
+	return b.invoke(b.Data, index, functor, input, func(_data *_Data) *_Result {
+		return functor(_data, input)
+	})
+}
+
+// invoke runs the function on the data, the function being the functor at index wrapped as an ICallable, through the
+// tape of the flow if it is recorded or replayed. The rate limit and the circuit breaker of the registry apply to a
+// functor given by name.
+func (b *BasicFlowNode) invoke(data *_Data, index int, functor interface{}, input interface{},
+	function ICallable) *_Result {
+	name := b.names.functor(index)
+	if b.tape != nil {
+		return b.tape.call(b.position, b.Note, displayName(name, functor), input, data, func() *_Result {
+			return b.execute(data, name, displayName(name, functor), function)
+		})
+	}
+	return b.execute(data, name, displayName(name, functor), function)
+}
+
+// execute runs the function. Every attempt of a hedged functor waits for the rate limiters and goes through the
+// circuit breakers.
+func (b *BasicFlowNode) execute(data *_Data, name string, display string, function ICallable) *_Result {
+	if breaker := b.registry.circuitBreakerOf(name); breaker != nil {
+		function = breaker.wrap(function)
+	}
+	if b.CircuitBreaker != nil {
+		function = b.CircuitBreaker.wrap(function)
+	}
+	attempt := func(_data *_Data) *_Result {
+		if result := b.limit(_data, name); result != nil {
+			return result
+		}
+		return function(_data)
+	}
+	// The functors of Parallel, Race and Quorum nodes already run concurrently, and the ones of Parallel nodes share the
+	// data the copy of an attempt would be put back into
+	if b.Hedge != nil && b.NodeType != ParallelNodeType && b.NodeType != RaceNodeType && b.NodeType != QuorumNodeType {
+		return b.Hedge.run(data, attempt, b.getClock(), b.Note, display)
+	}
+	return attempt(data)
+}
+
+// evaluate runs the condition of the node, through the tape of the flow if it is recorded or replayed
+func (b *BasicFlowNode) evaluate(condition IBoolFunc) bool {
+	if b.tape != nil {
+		return b.tape.condition(b.position, b.Note, displayName(b.names.condition, condition), b.Data, condition)
+	}
+	return condition(b.Data)
+}
+
+// conditionNotFound is the error of a conditional node without condition
+func (b *BasicFlowNode) conditionNotFound() *ConditionNotFoundError {
+	return &ConditionNotFoundError{Name: b.names.condition}
+}
+
+// limit waits for the rate limiters, a failure is returned if the context of the data is done before
+func (b *BasicFlowNode) limit(data *_Data, name string) *_Result {
+	if b.RateLimiter != nil {
+		if err := waitForToken(GetContext(data), b.RateLimiter, b.getClock(), b.Note, ""); err != nil {
+			return &_Result{Err: err}
+		}
+	}
+	if limiter := b.registry.rateLimitOf(name); limiter != nil {
+		if err := waitForToken(GetContext(data), limiter, b.getClock(), b.Note, name); err != nil {
+			return &_Result{Err: err}
+		}
+	}
+	return nil
+}
+
+// finishState records the outcome of the node once ImplTask has returned. A conditional node whose condition
+// was false keeps NotTakenNodeState, and a node ended by Return keeps ReturnedNodeState.
+func (b *BasicFlowNode) finishState() {
+	if b.GetParentResult().Err != nil || b.GetParentResult().StatusCode != 0 {
+		b.State = FailedNodeState
+	} else if b.State != NotTakenNodeState && b.State != ReturnedNodeState {
+		b.State = SucceededNodeState
+	}
+}
+
 //END BasicFlowNode
 
 //IfNode Implementation
@@ -223,19 +461,26 @@ func NewIfNode(data *_Data, parentResult **_Result, condition IBoolFunc, functor
 func (i *IfNode) ImplTask() *_Result {
 	if i.Condition == nil {
 		return &_Result{
-			Err:        NewConditionNotFoundError(),
+			Err:        i.conditionNotFound(),
 			StatusCode: 0,
 			StatusMsg:  "",
 		}
 	}
 
-	if i.Condition(i.Data) {
+	if i.evaluate(i.Condition) {
 		if i.BeginLogger != nil {
 			i.BeginLogger(i.Note, i.Data)
 		}
 
-		for _, functor := range i.Functors {
-			result := functor(i.Data)
+		for index, functor := range i.Functors {
+			result := i.call(index, functor)
+			if result == Return {
+				i.State = ReturnedNodeState
+				if i.EndLogger != nil {
+					i.EndLogger(i.Note, i.Data, i.GetParentResult())
+				}
+				return i.GetParentResult()
+			}
 			if result != nil && (result.Err != nil || result.StatusCode != 0) {
 				if i.EndLogger != nil {
 					i.EndLogger(i.Note, i.Data, result)
@@ -252,6 +497,8 @@ func (i *IfNode) ImplTask() *_Result {
 		if i.EndLogger != nil {
 			i.EndLogger(i.Note, i.Data, i.GetParentResult())
 		}
+	} else {
+		i.State = NotTakenNodeState
 	}
 
 	return i.GetParentResult()
@@ -259,13 +506,16 @@ func (i *IfNode) ImplTask() *_Result {
 
 func (i *IfNode) Run() {
 	if i.ShouldSkip || i.GetParentResult().Err != nil || i.GetParentResult().StatusCode != 0 {
+		i.State = SkippedNodeState
 		return
 	}
 
+	i.State = RunningNodeState
 	result := i.ImplTask()
 	if result != nil {
 		i.SetParentResult(result)
 	}
+	i.finishState()
 }
 
 //END IfNode
@@ -289,13 +539,13 @@ func NewIfSubPathNode(condition IBoolFunc, subEngine IFlowEngine, parent IFlowEn
 func (i *IfSubPathNode) ImplTask() *_Result {
 	if i.Condition == nil {
 		return &_Result{
-			Err:        NewConditionNotFoundError(),
+			Err:        i.conditionNotFound(),
 			StatusCode: 0,
 			StatusMsg:  "",
 		}
 	}
 
-	if i.Condition(i.Data) {
+	if i.evaluate(i.Condition) {
 		if i.BeginLogger != nil {
 			i.BeginLogger(i.Note, i.Data)
 		}
@@ -318,6 +568,8 @@ func (i *IfSubPathNode) ImplTask() *_Result {
 		if i.EndLogger != nil {
 			i.EndLogger(i.Note, i.Data, i.GetParentResult())
 		}
+	} else {
+		i.State = NotTakenNodeState
 	}
 
 	return i.GetParentResult()
@@ -325,13 +577,16 @@ func (i *IfSubPathNode) ImplTask() *_Result {
 
 func (i *IfSubPathNode) Run() {
 	if i.ShouldSkip || i.GetParentResult().Err != nil || i.GetParentResult().StatusCode != 0 {
+		i.State = SkippedNodeState
 		return
 	}
 
+	i.State = RunningNodeState
 	result := i.ImplTask()
 	if result != nil {
 		i.SetParentResult(result)
 	}
+	i.finishState()
 
 }
 
@@ -376,13 +631,13 @@ func NewElseIfSubPathNode(condition IBoolFunc, subEngine IFlowEngine, parent IFl
 func (e *ElseIfSubPathNode) ImplTask() *_Result {
 	if e.Condition == nil {
 		return &_Result{
-			Err:        NewConditionNotFoundError(),
+			Err:        e.conditionNotFound(),
 			StatusCode: 0,
 			StatusMsg:  "",
 		}
 	}
 
-	if e.Condition(e.Data) {
+	if e.evaluate(e.Condition) {
 		if e.BeginLogger != nil {
 			e.BeginLogger(e.Note, e.Data)
 		}
@@ -404,6 +659,8 @@ func (e *ElseIfSubPathNode) ImplTask() *_Result {
 		if e.EndLogger != nil {
 			e.EndLogger(e.Note, e.Data, e.GetParentResult())
 		}
+	} else {
+		e.State = NotTakenNodeState
 	}
 
 	return e.GetParentResult()
@@ -411,13 +668,16 @@ func (e *ElseIfSubPathNode) ImplTask() *_Result {
 
 func (e *ElseIfSubPathNode) Run() {
 	if e.ShouldSkip || e.GetParentResult().Err != nil || e.GetParentResult().StatusCode != 0 {
+		e.State = SkippedNodeState
 		return
 	}
 
+	e.State = RunningNodeState
 	result := e.ImplTask()
 	if result != nil {
 		e.SetParentResult(result)
 	}
+	e.finishState()
 }
 
 func (e *ElseIfSubPathNode) SetData(data *_Data) {
@@ -468,16 +728,19 @@ func (e *ElseSubPathNode) ImplTask() *_Result {
 
 func (e *ElseSubPathNode) Run() {
 	if e.ShouldSkip || e.GetParentResult().Err != nil || e.GetParentResult().StatusCode != 0 {
+		e.State = SkippedNodeState
 		return
 	}
 	if e.BeginLogger != nil {
 		e.BeginLogger(e.Note, e.Data)
 	}
 
+	e.State = RunningNodeState
 	result := e.ImplTask()
 	if result != nil {
 		e.SetParentResult(result)
 	}
+	e.finishState()
 
 	if e.EndLogger != nil {
 		e.EndLogger(e.Note, e.Data, e.GetParentResult())
@@ -517,8 +780,12 @@ func NewElseNode(data *_Data, parentResult **_Result, functors ...ICallable) *El
 }
 
 func (e *ElseNode) ImplTask() *_Result {
-	for _, functor := range e.Functors {
-		result := functor(e.Data)
+	for index, functor := range e.Functors {
+		result := e.call(index, functor)
+		if result == Return {
+			e.State = ReturnedNodeState
+			return e.GetParentResult()
+		}
 		if result != nil && (result.Err != nil || result.StatusCode != 0) {
 			return result
 		}
@@ -528,16 +795,19 @@ func (e *ElseNode) ImplTask() *_Result {
 
 func (e *ElseNode) Run() {
 	if e.ShouldSkip || e.GetParentResult().Err != nil || e.GetParentResult().StatusCode != 0 {
+		e.State = SkippedNodeState
 		return
 	}
 	if e.BeginLogger != nil {
 		e.BeginLogger(e.Note, e.Data)
 	}
 
+	e.State = RunningNodeState
 	result := e.ImplTask()
 	if result != nil {
 		e.SetParentResult(result)
 	}
+	e.finishState()
 
 	if e.EndLogger != nil {
 		e.EndLogger(e.Note, e.Data, e.GetParentResult())
@@ -564,18 +834,25 @@ func NewElseIfNode(data *_Data, parentResult **_Result, condition IBoolFunc, fun
 func (e *ElseIfNode) ImplTask() *_Result {
 	if e.Condition == nil {
 		return &_Result{
-			Err:        NewConditionNotFoundError(),
+			Err:        e.conditionNotFound(),
 			StatusCode: 0,
 			StatusMsg:  "",
 		}
 	}
 
-	if e.Condition(e.Data) {
+	if e.evaluate(e.Condition) {
 		if e.BeginLogger != nil {
 			e.BeginLogger(e.Note, e.Data)
 		}
-		for _, functor := range e.Functors {
-			result := functor(e.Data)
+		for index, functor := range e.Functors {
+			result := e.call(index, functor)
+			if result == Return {
+				e.State = ReturnedNodeState
+				if e.EndLogger != nil {
+					e.EndLogger(e.Note, e.Data, e.GetParentResult())
+				}
+				return e.GetParentResult()
+			}
 			if result != nil && (result.Err != nil || result.StatusCode != 0) {
 				if e.EndLogger != nil {
 					e.EndLogger(e.Note, e.Data, result)
@@ -593,6 +870,8 @@ func (e *ElseIfNode) ImplTask() *_Result {
 		if e.EndLogger != nil {
 			e.EndLogger(e.Note, e.Data, e.GetParentResult())
 		}
+	} else {
+		e.State = NotTakenNodeState
 	}
 
 	return e.GetParentResult()
@@ -600,13 +879,16 @@ func (e *ElseIfNode) ImplTask() *_Result {
 
 func (e *ElseIfNode) Run() {
 	if e.ShouldSkip || e.GetParentResult().Err != nil || e.GetParentResult().StatusCode != 0 {
+		e.State = SkippedNodeState
 		return
 	}
 
+	e.State = RunningNodeState
 	result := e.ImplTask()
 	if result != nil {
 		e.SetParentResult(result)
 	}
+	e.finishState()
 
 }
 
@@ -623,8 +905,12 @@ func NewNormalNode(data *_Data, parentResult **_Result, functors ...ICallable) *
 }
 
 func (n *NormalNode) ImplTask() *_Result {
-	for _, functor := range n.Functors {
-		result := functor(n.Data)
+	for index, functor := range n.Functors {
+		result := n.call(index, functor)
+		if result == Return {
+			n.State = ReturnedNodeState
+			return n.GetParentResult()
+		}
 		if result != nil && (result.Err != nil || result.StatusCode != 0) {
 			return result
 		}
@@ -634,16 +920,19 @@ func (n *NormalNode) ImplTask() *_Result {
 
 func (n *NormalNode) Run() {
 	if n.ShouldSkip || n.GetParentResult().Err != nil || n.GetParentResult().StatusCode != 0 {
+		n.State = SkippedNodeState
 		return
 	}
 	if n.BeginLogger != nil {
 		n.BeginLogger(n.Note, n.Data)
 	}
 
+	n.State = RunningNodeState
 	result := n.ImplTask()
 	if result != nil {
 		n.SetParentResult(result)
 	}
+	n.finishState()
 
 	if n.EndLogger != nil {
 		n.EndLogger(n.Note, n.Data, n.GetParentResult())
@@ -669,8 +958,12 @@ func NewForNode(times int, data *_Data, parentResult **_Result, functors ...ICal
 
 func (f *ForNode) ImplTask() *_Result {
 	for i := 0; i < f.Times; i++ {
-		for _, functor := range f.Functors {
-			result := functor(f.Data)
+		for index, functor := range f.Functors {
+			result := f.call(index, functor)
+			if result == Return {
+				f.State = ReturnedNodeState
+				return f.GetParentResult()
+			}
 			if result != nil && (result.Err != nil || result.StatusCode != 0) {
 				return result
 			}
@@ -681,16 +974,19 @@ func (f *ForNode) ImplTask() *_Result {
 
 func (f *ForNode) Run() {
 	if f.ShouldSkip || f.GetParentResult().Err != nil || f.GetParentResult().StatusCode != 0 {
+		f.State = SkippedNodeState
 		return
 	}
 	if f.BeginLogger != nil {
 		f.BeginLogger(f.Note, f.Data)
 	}
 
+	f.State = RunningNodeState
 	result := f.ImplTask()
 	if result != nil {
 		f.SetParentResult(result)
 	}
+	f.finishState()
 
 	if f.EndLogger != nil {
 		f.EndLogger(f.Note, f.Data, f.GetParentResult())
@@ -724,8 +1020,8 @@ func (p *ParallelNode) ImplTask() *_Result {
 		close(resultChan)
 	}(&wg)
 
-	for _, functor := range p.Functors {
-		go func(wg *sync.WaitGroup, f ICallable) {
+	for index, functor := range p.Functors {
+		go func(wg *sync.WaitGroup, index int, f ICallable) {
 			defer func() {
 				wg.Done()
 				if a := recover(); a != nil {
@@ -737,13 +1033,17 @@ func (p *ParallelNode) ImplTask() *_Result {
 					}
 				}
 			}()
-			result := f(p.Data)
+			result := p.call(index, f)
 			resultChan <- result
-		}(&wg, functor)
+		}(&wg, index, functor)
 	}
 
 	result := p.GetParentResult()
 	for item := range resultChan {
+		if item == Return {
+			p.State = ReturnedNodeState
+			continue
+		}
 		if result != nil && (result.StatusCode != 0 || result.Err != nil) {
 			continue
 		}
@@ -755,16 +1055,19 @@ func (p *ParallelNode) ImplTask() *_Result {
 
 func (p *ParallelNode) Run() {
 	if p.ShouldSkip || p.GetParentResult().Err != nil || p.GetParentResult().StatusCode != 0 {
+		p.State = SkippedNodeState
 		return
 	}
 	if p.BeginLogger != nil {
 		p.BeginLogger(p.Note, p.Data)
 	}
 
+	p.State = RunningNodeState
 	result := p.ImplTask()
 	if result != nil {
 		p.SetParentResult(result)
 	}
+	p.finishState()
 
 	if p.EndLogger != nil {
 		p.EndLogger(p.Note, p.Data, p.GetParentResult())
@@ -789,8 +1092,12 @@ func NewPrepareNode(data *_Data, parentResult **_Result, input _PrepareInput, fu
 }
 
 func (p *PrepareNode) ImplTask() *_Result {
-	for _, functor := range p.Functors {
-		result := functor(p.Data, p.Input)
+	for index, functor := range p.Functors {
+		result := p.callPrepare(index, functor, p.Input)
+		if result == Return {
+			p.State = ReturnedNodeState
+			return p.GetParentResult()
+		}
 		if result != nil && (result.Err != nil || result.StatusCode != 0) {
 			return result
 		}
@@ -800,16 +1107,19 @@ func (p *PrepareNode) ImplTask() *_Result {
 
 func (p *PrepareNode) Run() {
 	if p.ShouldSkip || p.GetParentResult().Err != nil || p.GetParentResult().StatusCode != 0 {
+		p.State = SkippedNodeState
 		return
 	}
 	if p.BeginLogger != nil {
 		p.BeginLogger(p.Note, p.Data)
 	}
 
+	p.State = RunningNodeState
 	result := p.ImplTask()
 	if result != nil {
 		p.SetParentResult(result)
 	}
+	p.finishState()
 
 	if p.EndLogger != nil {
 		p.EndLogger(p.Note, p.Data, p.GetParentResult())
@@ -818,171 +1128,936 @@ func (p *PrepareNode) Run() {
 
 //END PrepareNode
 
-//FlowEngine Implementation
+//FinallyNode Implementation
+type FinallyNode struct {
+	*BasicFlowNode
+	Functors []ICallable
+}
 
-type FlowEngine struct {
-	data          *_Data
-	nodes         []IBasicFlowNode
-	result        **_Result
-	onFailFunc    IOnFailFunc
-	onSuccessFunc IOnSuccessFunc
+func NewFinallyNode(data *_Data, parentResult **_Result, functors ...ICallable) *FinallyNode {
+	return &FinallyNode{BasicFlowNode: NewBasicFlowNode(data, parentResult, FinallyNodeType), Functors: functors}
 }
 
-func NewFlowEngine() *FlowEngine {
-	res := &FlowEngine{
-		nodes: make([]IBasicFlowNode, 0, 10),
+// ImplTask returns nil if every functor succeeds, so that the failure of an earlier node is kept
+func (f *FinallyNode) ImplTask() *_Result {
+	for index, functor := range f.Functors {
+		result := f.call(index, functor)
+		if result != nil && (result.Err != nil || result.StatusCode != 0) {
+			return result
+		}
 	}
-	res.data = new(_Data)
-
-	tempResult := new(_Result)
-	res.result = &tempResult
-	return res
+	return nil
 }
 
-func (f *FlowEngine) Prepare(input _PrepareInput, prepareFunc ...IPrepareFunc) *FlowEngine {
-	node := NewPrepareNode(f.data, f.result, input, prepareFunc...)
-	if len(f.nodes) != 0 {
-		f.nodes[len(f.nodes)-1].SetNext(node)
+// Run does not skip the node when an earlier node has failed. The failure is only replaced if a functor of the
+// node fails as well.
+func (f *FinallyNode) Run() {
+	if f.ShouldSkip {
+		f.State = SkippedNodeState
+		return
+	}
+	if f.BeginLogger != nil {
+		f.BeginLogger(f.Note, f.Data)
 	}
-	f.nodes = append(f.nodes, node)
-	return f
-}
 
-func (f *FlowEngine) Do(functors ...ICallable) *FlowEngine {
-	node := NewNormalNode(f.data, f.result, functors...)
-	if len(f.nodes) != 0 {
-		f.nodes[len(f.nodes)-1].SetNext(node)
+	f.State = RunningNodeState
+	result := f.ImplTask()
+	if result != nil {
+		f.SetParentResult(result)
+		f.State = FailedNodeState
+	} else {
+		f.State = SucceededNodeState
 	}
-	f.nodes = append(f.nodes, node)
-	return f
-}
 
-func (f *FlowEngine) For(times int, functors ...ICallable) *FlowEngine {
-	node := NewForNode(times, f.data, f.result, functors...)
-	if len(f.nodes) != 0 {
-		f.nodes[len(f.nodes)-1].SetNext(node)
+	if f.EndLogger != nil {
+		f.EndLogger(f.Note, f.Data, f.GetParentResult())
 	}
-	f.nodes = append(f.nodes, node)
-	return f
 }
 
-func (f *FlowEngine) Parallel(functors ...ICallable) *FlowEngine {
-	node := NewParallelNode(f.data, f.result, functors...)
-	if len(f.nodes) != 0 {
-		f.nodes[len(f.nodes)-1].SetNext(node)
-	}
-	f.nodes = append(f.nodes, node)
-	return f
+//END FinallyNode
+
+//FinallySubPathNode Implementation
+type FinallySubPathNode struct {
+	*BasicFlowNode
+	SubPath IFlowEngine
 }
 
-func (f *FlowEngine) If(condition IBoolFunc, functors ...ICallable) *ElseFlowEngine {
-	node := NewIfNode(f.data, f.result, condition, functors...)
-	if len(f.nodes) != 0 {
-		f.nodes[len(f.nodes)-1].SetNext(node)
+func NewFinallySubPathNode(subEngine IFlowEngine, parent IFlowEngine) *FinallySubPathNode {
+	subEngine.Attach(parent)
+	return &FinallySubPathNode{
+		BasicFlowNode: NewBasicFlowNode(subEngine.getData(), subEngine.getResult(), FinallySubPathNodeType),
+		SubPath:       subEngine,
 	}
-	f.nodes = append(f.nodes, node)
-	return NewElseFlowEngine(&f.data, f, f.result, &f.nodes)
 }
 
-func (f *FlowEngine) IfSubPath(condition IBoolFunc, subPath IFlowEngine) *ElseFlowEngine {
-	node := NewIfSubPathNode(condition, subPath, f)
-	if len(f.nodes) != 0 {
-		f.nodes[len(f.nodes)-1].SetNext(node)
+// ImplTask runs the sub-path with a clean result, otherwise its nodes would be skipped after an earlier failure.
+// The earlier result is put back if the sub-path succeeds.
+func (f *FinallySubPathNode) ImplTask() *_Result {
+	if f.SubPath == nil {
+		return nil
 	}
-	f.nodes = append(f.nodes, node)
-	return NewElseFlowEngine(&f.data, f, f.result, &f.nodes)
+	original := f.GetParentResult()
+	f.SetParentResult(new(_Result))
+	result := f.SubPath.Wait()
+	if result != nil && (result.Err != nil || result.StatusCode != 0) {
+		return result
+	}
+	f.SetParentResult(original)
+	return nil
 }
 
-func (f *FlowEngine) Wait() *_Result {
-	for _, node := range f.nodes {
-		node.Run()
+func (f *FinallySubPathNode) Run() {
+	if f.ShouldSkip {
+		f.State = SkippedNodeState
+		return
 	}
-	if f.onSuccessFunc != nil {
-		if (*f.result).Err == nil && (*f.result).StatusCode == 0 {
-			f.onSuccessFunc(f.data, *f.result)
-		}
+	if f.BeginLogger != nil {
+		f.BeginLogger(f.Note, f.Data)
 	}
-	if f.onFailFunc != nil {
-		if (*f.result).Err != nil || (*f.result).StatusCode != 0 {
-			f.onFailFunc(f.data, *f.result)
-		}
+
+	f.State = RunningNodeState
+	result := f.ImplTask()
+	if result != nil {
+		f.SetParentResult(result)
+		f.State = FailedNodeState
+	} else {
+		f.State = SucceededNodeState
 	}
-	return *f.result
-}
 
-func (f *FlowEngine) SetNote(note string) *FlowEngine {
-	if len(f.nodes) != 0 {
-		f.nodes[len(f.nodes)-1].SetNote(note)
+	if f.EndLogger != nil {
+		f.EndLogger(f.Note, f.Data, f.GetParentResult())
 	}
-	return f
 }
 
-func (f *FlowEngine) SetBeginLogger(logger INodeBeginLogger) *FlowEngine {
-	if len(f.nodes) != 0 {
-		f.nodes[len(f.nodes)-1].SetBeginLogger(logger)
+func (f *FinallySubPathNode) SetData(data *_Data) {
+	if f.SubPath != nil {
+		if len(f.SubPath.getNodes()) != 0 {
+			for current := f.SubPath.getNodes()[0]; current != nil; current = current.GetNext() {
+				current.SetData(data)
+			}
+		}
 	}
-	return f
 }
 
-func (f *FlowEngine) SetEndLogger(logger INodeEndLogger) *FlowEngine {
-	if len(f.nodes) != 0 {
-		f.nodes[len(f.nodes)-1].SetEndLogger(logger)
+func (f *FinallySubPathNode) SetResultPtr(result **_Result) {
+	if f.SubPath != nil {
+		if len(f.SubPath.getNodes()) != 0 {
+			for current := f.SubPath.getNodes()[0]; current != nil; current = current.GetNext() {
+				current.SetResultPtr(result)
+			}
+		}
 	}
-	return f
 }
 
-func (f *FlowEngine) SetGlobalBeginLogger(logger INodeBeginLogger) *FlowEngine {
-	for _, note := range f.nodes {
-		if note.GetBeginLogger() == nil {
-			note.SetBeginLogger(logger)
-		}
+//END FinallySubPathNode
+
+//TrySubPathNode Implementation
+type TrySubPathNode struct {
+	*BasicFlowNode
+	SubPath IFlowEngine
+}
+
+func NewTrySubPathNode(subEngine IFlowEngine, parent IFlowEngine) *TrySubPathNode {
+	subEngine.Attach(parent)
+	return &TrySubPathNode{
+		BasicFlowNode: NewBasicFlowNode(subEngine.getData(), subEngine.getResult(), TrySubPathNodeType),
+		SubPath:       subEngine,
 	}
-	return f
 }
 
-func (f *FlowEngine) SetGlobalEndLogger(logger INodeEndLogger) *FlowEngine {
-	for _, note := range f.nodes {
-		if note.GetEndLogger() == nil {
-			note.SetEndLogger(logger)
+func (t *TrySubPathNode) ImplTask() *_Result {
+	if t.SubPath != nil {
+		result := t.SubPath.Wait()
+		if result != nil && (result.Err != nil || result.StatusCode != 0) {
+			return result
 		}
 	}
-	return f
+	return t.GetParentResult()
 }
 
-func (f *FlowEngine) OnFail(functor IOnFailFunc) *FlowEngine {
-	f.onFailFunc = functor
-	return f
-}
+func (t *TrySubPathNode) Run() {
+	if t.ShouldSkip || t.GetParentResult().Err != nil || t.GetParentResult().StatusCode != 0 {
+		t.State = SkippedNodeState
+		return
+	}
+	if t.BeginLogger != nil {
+		t.BeginLogger(t.Note, t.Data)
+	}
 
-func (f *FlowEngine) OnSuccess(functor IOnSuccessFunc) *FlowEngine {
-	f.onSuccessFunc = functor
-	return f
-}
+	t.State = RunningNodeState
+	result := t.ImplTask()
+	if result != nil {
+		t.SetParentResult(result)
+	}
+	t.finishState()
 
-func (f *FlowEngine) getData() *_Data {
-	return f.data
+	if t.EndLogger != nil {
+		t.EndLogger(t.Note, t.Data, t.GetParentResult())
+	}
 }
 
-func (f *FlowEngine) setData(data *_Data) {
-	f.data = data
+func (t *TrySubPathNode) SetData(data *_Data) {
+	if t.SubPath != nil {
+		if len(t.SubPath.getNodes()) != 0 {
+			for current := t.SubPath.getNodes()[0]; current != nil; current = current.GetNext() {
+				current.SetData(data)
+			}
+		}
+	}
 }
 
-func (f *FlowEngine) getResult() **_Result {
-	return f.result
+func (t *TrySubPathNode) SetResultPtr(result **_Result) {
+	if t.SubPath != nil {
+		if len(t.SubPath.getNodes()) != 0 {
+			for current := t.SubPath.getNodes()[0]; current != nil; current = current.GetNext() {
+				current.SetResultPtr(result)
+			}
+		}
+	}
 }
 
-func (f *FlowEngine) setResult(result **_Result) {
-	f.result = result
+//END TrySubPathNode
+
+//CatchNode Implementation
+type CatchNode struct {
+	*BasicFlowNode
+	// Match selects the failures handled by the node, a nil Match handles every failure
+	Match   ICatchMatcher
+	Handler ICatchFunc
+	// Try is the TrySubPathNode the node is scoped to, nil if the node handles every earlier failure of the flow
+	Try *TrySubPathNode
+	// Previous is the Catch right before this one, only the first matching Catch of a row handles the failure
+	Previous *CatchNode
 }
 
-func (f *FlowEngine) getOnFailFunc() IOnFailFunc {
-	return f.onFailFunc
+func NewCatchNode(data *_Data, parentResult **_Result, match ICatchMatcher, handler ICatchFunc) *CatchNode {
+	return &CatchNode{
+		BasicFlowNode: NewBasicFlowNode(data, parentResult, CatchNodeType),
+		Match:         match,
+		Handler:       handler,
+	}
+}
+
+// ImplTask clears the failure if the handler returns nil or a successful result, otherwise the failure is replaced
+// by the result of the handler
+func (c *CatchNode) ImplTask() *_Result {
+	failure := c.GetParentResult()
+	if (c.Try != nil && c.Try.GetState() != FailedNodeState) || (c.Match != nil && !c.Match(failure)) {
+		c.State = NotTakenNodeState
+		return failure
+	}
+	if c.Handler == nil {
+		return new(_Result)
+	}
+	result := c.Handler(c.Data, failure)
+	if result == nil {
+		return new(_Result)
+	}
+	if result == Return {
+		c.State = ReturnedNodeState
+		return new(_Result)
+	}
+	return result
+}
+
+func (c *CatchNode) Run() {
+	if c.ShouldSkip || (c.Previous != nil && c.Previous.GetState() != NotTakenNodeState) {
+		c.State = SkippedNodeState
+		return
+	}
+	if c.GetParentResult().Err == nil && c.GetParentResult().StatusCode == 0 {
+		c.State = NotTakenNodeState
+		return
+	}
+	if c.BeginLogger != nil {
+		c.BeginLogger(c.Note, c.Data)
+	}
+
+	c.State = RunningNodeState
+	result := c.ImplTask()
+	if result != nil {
+		c.SetParentResult(result)
+	}
+	if c.State != NotTakenNodeState {
+		c.finishState()
+	}
+
+	if c.EndLogger != nil {
+		c.EndLogger(c.Note, c.Data, c.GetParentResult())
+	}
+}
+
+//END CatchNode
+
+//RaceNode Implementation
+type RaceNode struct {
+	*BasicFlowNode
+	Functors []ICallable
+}
+
+func NewRaceNode(data *_Data, parentResult **_Result, functors ...ICallable) *RaceNode {
+	return &RaceNode{BasicFlowNode: NewBasicFlowNode(data, parentResult, RaceNodeType), Functors: functors}
+}
+
+func (r *RaceNode) ImplTask() *_Result {
+	branches := make([]func(data *_Data) *_Result, 0, len(r.Functors))
+	for index, functor := range r.Functors {
+		index, f := index, functor
+		branches = append(branches, func(data *_Data) *_Result {
+			return r.callOn(data, index, f)
+		})
+	}
+	result := race(r.Data, newCopies(len(branches)), branches, cancelPending)
+	if result == Return {
+		r.State = ReturnedNodeState
+		return r.GetParentResult()
+	}
+	if result != nil {
+		return result
+	}
+	return r.GetParentResult()
+}
+
+func (r *RaceNode) Run() {
+	if r.ShouldSkip || r.GetParentResult().Err != nil || r.GetParentResult().StatusCode != 0 {
+		r.State = SkippedNodeState
+		return
+	}
+	if r.BeginLogger != nil {
+		r.BeginLogger(r.Note, r.Data)
+	}
+
+	r.State = RunningNodeState
+	result := r.ImplTask()
+	if result != nil {
+		r.SetParentResult(result)
+	}
+	r.finishState()
+
+	if r.EndLogger != nil {
+		r.EndLogger(r.Note, r.Data, r.GetParentResult())
+	}
+}
+
+//END RaceNode
+
+//RaceSubPathNode Implementation
+type RaceSubPathNode struct {
+	*BasicFlowNode
+	SubPaths []IFlowEngine
+	// copies are the data the sub-paths are attached to
+	copies []*_Data
+}
+
+func NewRaceSubPathNode(data *_Data, parentResult **_Result, subPaths ...IFlowEngine) *RaceSubPathNode {
+	node := &RaceSubPathNode{BasicFlowNode: NewBasicFlowNode(data, parentResult, RaceSubPathNodeType), SubPaths: subPaths}
+	for _, subPath := range subPaths {
+		node.copies = append(node.copies, attachBranch(subPath))
+	}
+	return node
+}
+
+func (r *RaceSubPathNode) ImplTask() *_Result {
+	branches := make([]func(data *_Data) *_Result, 0, len(r.SubPaths))
+	for _, subPath := range r.SubPaths {
+		branches = append(branches, subPathBranch(subPath))
+	}
+	result := race(r.Data, r.copies, branches, waitPending)
+	if result != nil && result != Return {
+		return result
+	}
+	return r.GetParentResult()
+}
+
+func (r *RaceSubPathNode) Run() {
+	if r.ShouldSkip || r.GetParentResult().Err != nil || r.GetParentResult().StatusCode != 0 {
+		r.State = SkippedNodeState
+		return
+	}
+	if r.BeginLogger != nil {
+		r.BeginLogger(r.Note, r.Data)
+	}
+
+	r.State = RunningNodeState
+	result := r.ImplTask()
+	if result != nil {
+		r.SetParentResult(result)
+	}
+	r.finishState()
+
+	if r.EndLogger != nil {
+		r.EndLogger(r.Note, r.Data, r.GetParentResult())
+	}
+}
+
+//END RaceSubPathNode
+
+//QuorumNode Implementation
+type QuorumNode struct {
+	*BasicFlowNode
+	Needed   int
+	Functors []ICallable
+	// CancelRemaining cancels the context of the functors still running once the quorum is decided
+	CancelRemaining bool
+	Result          *QuorumResult
+}
+
+func NewQuorumNode(needed int, data *_Data, parentResult **_Result, functors ...ICallable) *QuorumNode {
+	return &QuorumNode{
+		BasicFlowNode: NewBasicFlowNode(data, parentResult, QuorumNodeType),
+		Needed:        needed,
+		Functors:      functors,
+	}
+}
+
+func (q *QuorumNode) ImplTask() *_Result {
+	q.Result = quorum(q)
+	if !q.Result.Reached {
+		return &_Result{Err: NewQuorumError(q.Result)}
+	}
+	return q.GetParentResult()
+}
+
+func (q *QuorumNode) Run() {
+	if q.ShouldSkip || q.GetParentResult().Err != nil || q.GetParentResult().StatusCode != 0 {
+		q.State = SkippedNodeState
+		return
+	}
+	if q.BeginLogger != nil {
+		q.BeginLogger(q.Note, q.Data)
+	}
+
+	q.State = RunningNodeState
+	result := q.ImplTask()
+	if result != nil {
+		q.SetParentResult(result)
+	}
+	q.finishState()
+
+	if q.EndLogger != nil {
+		q.EndLogger(q.Note, q.Data, q.GetParentResult())
+	}
+}
+
+//END QuorumNode
+
+//SignalNode Implementation
+type SignalNode struct {
+	*BasicFlowNode
+	Name    string
+	Timeout time.Duration
+	// Apply puts the payload of the signal into the data, the payload is dropped if it is nil
+	Apply ISignalFunc
+}
+
+func NewSignalNode(name string, timeout time.Duration, data *_Data, parentResult **_Result) *SignalNode {
+	return &SignalNode{
+		BasicFlowNode: NewBasicFlowNode(data, parentResult, SignalNodeType),
+		Name:          name,
+		Timeout:       timeout,
+	}
+}
+
+func (s *SignalNode) ImplTask() *_Result {
+	ctx := GetContext(s.Data)
+	handle := handleOf(ctx)
+	if handle == nil {
+		return &_Result{Err: NewSignalUnavailableError(s.Name)}
+	}
+	payload, err := handle.receive(ctx, s.Name, s.Timeout, s.getClock())
+	if err != nil {
+		return &_Result{Err: err}
+	}
+	if s.Apply != nil {
+		result := s.Apply(s.Data, payload)
+		if result != nil && (result.Err != nil || result.StatusCode != 0) {
+			return result
+		}
+	}
+	return s.GetParentResult()
+}
+
+func (s *SignalNode) Run() {
+	if s.ShouldSkip || s.GetParentResult().Err != nil || s.GetParentResult().StatusCode != 0 {
+		s.State = SkippedNodeState
+		return
+	}
+	if s.BeginLogger != nil {
+		s.BeginLogger(s.Note, s.Data)
+	}
+
+	s.State = RunningNodeState
+	result := s.ImplTask()
+	if result != nil {
+		s.SetParentResult(result)
+	}
+	s.finishState()
+
+	if s.EndLogger != nil {
+		s.EndLogger(s.Note, s.Data, s.GetParentResult())
+	}
+}
+
+//END SignalNode
+
+//FlowEngine Implementation
+
+type FlowEngine struct {
+	data          *_Data
+	nodes         []IBasicFlowNode
+	result        **_Result
+	onFailFunc    IOnFailFunc
+	onSuccessFunc IOnSuccessFunc
+	failHandlers  []*failHandler
+	registry      *Registry
+	checkpointer  checkpointer
+	attached      bool
+	compensations []*CompensationResult
+	ctx           context.Context
+	handle        *Handle
+	clock         Clock
+	coverage      *Coverage
+	coverageName  string
+	tape          tape
+	position      string
+}
+
+func NewFlowEngine() *FlowEngine {
+	res := &FlowEngine{
+		nodes:    make([]IBasicFlowNode, 0, 10),
+		registry: DefaultRegistry,
+	}
+	res.data = new(_Data)
+
+	tempResult := new(_Result)
+	res.result = &tempResult
+	return res
+}
+
+func (f *FlowEngine) Prepare(input _PrepareInput, prepareFunc ...IPrepareFunc) *FlowEngine {
+	node := NewPrepareNode(f.data, f.result, input, prepareFunc...)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+func (f *FlowEngine) Do(functors ...ICallable) *FlowEngine {
+	node := NewNormalNode(f.data, f.result, functors...)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+func (f *FlowEngine) For(times int, functors ...ICallable) *FlowEngine {
+	node := NewForNode(times, f.data, f.result, functors...)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+func (f *FlowEngine) Parallel(functors ...ICallable) *FlowEngine {
+	node := NewParallelNode(f.data, f.result, functors...)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+// Race runs the functors concurrently and goes on as soon as one of them succeeds, with its result. The context of the
+// others is cancelled, and the node fails with RaceError only if all of them fail. Every functor runs on a copy of the
+// data, and the copy of the one which succeeded is put back into the data. The copies share what the pointers, maps
+// and slices of the data refer to, which the functors must therefore not change.
+func (f *FlowEngine) Race(functors ...ICallable) *FlowEngine {
+	node := NewRaceNode(f.data, f.result, functors...)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+// RaceSubPath is Race with sub-flows, every sub-flow has a result of its own and runs on a copy of the data like the
+// functors of Race do, with the same restriction. The context of the sub-flows which lost is cancelled, which stops
+// them before their next node, and the node waits for them to stop so that their states are known once it completes.
+func (f *FlowEngine) RaceSubPath(subPaths ...IFlowEngine) *FlowEngine {
+	node := NewRaceSubPathNode(f.data, f.result, subPaths...)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+// Quorum runs the functors concurrently and succeeds once needed of them have succeeded. It fails with QuorumError as
+// soon as too many have failed to reach the quorum. It does not wait for the other functors once the quorum is decided,
+// and QuorumResults tells the outcome of every functor.
+func (f *FlowEngine) Quorum(needed int, functors ...ICallable) *FlowEngine {
+	node := NewQuorumNode(needed, f.data, f.result, functors...)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+// WaitForSignal suspends the flow until Signal of its Handle sends the signal name, and fails with SignalTimeoutError if
+// it does not arrive within timeout, 0 meaning no timeout. SetSignalApply tells how the payload changes the data.
+func (f *FlowEngine) WaitForSignal(name string, timeout time.Duration) *FlowEngine {
+	node := NewSignalNode(name, timeout, f.data, f.result)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+func (f *FlowEngine) If(condition IBoolFunc, functors ...ICallable) *ElseFlowEngine {
+	node := NewIfNode(f.data, f.result, condition, functors...)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return NewElseFlowEngine(&f.data, f, f.result, &f.nodes)
+}
+
+func (f *FlowEngine) IfSubPath(condition IBoolFunc, subPath IFlowEngine) *ElseFlowEngine {
+	node := NewIfSubPathNode(condition, subPath, f)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return NewElseFlowEngine(&f.data, f, f.result, &f.nodes)
+}
+
+// Finally adds functors which run even if an earlier node has failed. They keep the earlier failure unless they fail
+// themselves.
+func (f *FlowEngine) Finally(functors ...ICallable) *FlowEngine {
+	node := NewFinallyNode(f.data, f.result, functors...)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+func (f *FlowEngine) FinallySubPath(subPath IFlowEngine) *FlowEngine {
+	node := NewFinallySubPathNode(subPath, f)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+// TrySubPath adds a sub-flow whose failures are handled by the Catch nodes added right after it
+func (f *FlowEngine) TrySubPath(subPath IFlowEngine) *FlowEngine {
+	node := NewTrySubPathNode(subPath, f)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+// Catch handles the failure of the flow so far, or of the TrySubPath right before it, if it is selected by match.
+// The handler either clears the failure so that the following nodes run, or replaces it with another failure.
+func (f *FlowEngine) Catch(match ICatchMatcher, handler ICatchFunc) *FlowEngine {
+	node := NewCatchNode(f.data, f.result, match, handler)
+	node.SetRegistry(f.registry)
+	if len(f.nodes) != 0 {
+		scopeCatch(node, f.nodes[len(f.nodes)-1])
+		f.nodes[len(f.nodes)-1].SetNext(node)
+	}
+	f.nodes = append(f.nodes, node)
+	return f
+}
+
+func (f *FlowEngine) Wait() *_Result {
+	resetNodeStates(f)
+	return f.run(0)
+}
+
+// Resume continues the flow from the node after the last one completed in the checkpoint of flowID, or runs it from
+// the beginning if there is no checkpoint. The result of the previous run is cleared, so the flow can be resumed again
+// after a failure. The checkpoint store must be set by SetCheckpointStore.
+func (f *FlowEngine) Resume(flowID string) *_Result {
+	resetNodeStates(f)
+	*f.result = new(_Result)
+	f.checkpointer.flowID = flowID
+	start, err := f.checkpointer.restore(f.nodes, f.data)
+	if err != nil {
+		*f.result = &_Result{Err: err}
+	}
+	return f.run(start)
+}
+
+func (f *FlowEngine) run(start int) *_Result {
+	if f.tape != nil && !f.attached {
+		f.tape.begin(f.data, f.result)
+	}
+	returned := returnedBefore(f.nodes, start)
+	for index := start; index < len(f.nodes); index++ {
+		f.handle.enter(index, f.nodes[index])
+		cancelled(f.ctx, f.result)
+		f.nodes[index].SetClock(f.clock)
+		f.nodes[index].setTape(f.tape, nodePosition(f.position, index))
+		for subIndex, subPath := range subPathsOf(f.nodes[index]) {
+			subPath.setContext(f.ctx)
+			subPath.setClock(f.clock)
+			subPath.setTape(f.tape, subPathPosition(f.position, index, subIndex))
+		}
+		if returned && !runsAfterReturn(f.nodes[index]) {
+			f.nodes[index].SetState(SkippedNodeState)
+		} else {
+			f.checkpointer.suspend(f.nodes, index, f.data, f.result)
+			runNode(f.nodes[index], f.data, *f.result)
+		}
+		returned = returned || f.nodes[index].GetState() == ReturnedNodeState
+		f.checkpointer.save(f.nodes, index, f.data, f.result)
+	}
+	f.checkpointer.finish(*f.result)
+	if !f.attached {
+		f.compensations = compensate(f, f.data, f.result)
+		if len(f.compensations) != 0 {
+			f.checkpointer.discard()
+		}
+	}
+	if f.onSuccessFunc != nil {
+		if (*f.result).Err == nil && (*f.result).StatusCode == 0 {
+			f.onSuccessFunc(f.data, *f.result)
+		}
+	}
+	if onFail := failHandlerFor(f.failHandlers, f.onFailFunc, *f.result); onFail != nil {
+		if (*f.result).Err != nil || (*f.result).StatusCode != 0 {
+			onFail(f.data, *f.result)
+		}
+	}
+	if f.coverage != nil && !f.attached {
+		f.coverage.recordRun(f.coverageName, f)
+	}
+	if f.tape != nil && !f.attached {
+		f.tape.end(*f.result)
+	}
+	return *f.result
+}
+
+// Compensate sets the functor undoing the last node. It runs if the node completed but the flow fails later.
+func (f *FlowEngine) Compensate(functor ICallable) *FlowEngine {
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetCompensation(functor)
+	}
+	return f
+}
+
+// SetRateLimit makes every functor of the last node wait for a token of the limiter before it is called
+func (f *FlowEngine) SetRateLimit(limiter IRateLimiter) *FlowEngine {
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetRateLimit(limiter)
+	}
+	return f
+}
+
+// SetCircuitBreaker makes the functors of the last node go through the breaker
+func (f *FlowEngine) SetCircuitBreaker(breaker *CircuitBreaker) *FlowEngine {
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetCircuitBreaker(breaker)
+	}
+	return f
+}
+
+// SetClock sets the clock the nodes of the flow and its sub-paths read the time from, SystemClock by default. The rate
+// limiters, circuit breakers and caches shared by the flows have their own clock.
+func (f *FlowEngine) SetClock(clock Clock) *FlowEngine {
+	f.clock = clock
+	return f
+}
+
+// SetSignalApply sets the function putting the payload of the signal into the data, the last node must be a
+// WaitForSignal
+func (f *FlowEngine) SetSignalApply(apply ISignalFunc) *FlowEngine {
+	if len(f.nodes) != 0 {
+		if node, ok := f.nodes[len(f.nodes)-1].(*SignalNode); ok {
+			node.Apply = apply
+		}
+	}
+	return f
+}
+
+// SetQuorumCancel makes the last node, which must be a Quorum, cancel the context of the functors still running once
+// the quorum is reached or out of reach, instead of letting them return in the background with their context
+func (f *FlowEngine) SetQuorumCancel(cancel bool) *FlowEngine {
+	if len(f.nodes) != 0 {
+		if node, ok := f.nodes[len(f.nodes)-1].(*QuorumNode); ok {
+			node.CancelRemaining = cancel
+		}
+	}
+	return f
+}
+
+// SetHedge calls every functor of the last node again when it has not finished after delay, up to maxExtra more
+// times, and takes the first attempt which succeeds. The attempts run on copies of the data, see Hedge.
+func (f *FlowEngine) SetHedge(delay time.Duration, maxExtra int) *FlowEngine {
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetHedge(NewHedge(delay, maxExtra))
+	}
+	return f
+}
+
+// SetCache skips the last node when the cache has an outcome under the key of the data, the node must be a Do, For,
+// Parallel or Prepare. SetCacheApply tells how the outcome changes the data.
+func (f *FlowEngine) SetCache(keyFn ICacheKeyFunc, cache ICache, ttl time.Duration) *FlowEngine {
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetCache(&NodeCache{Key: keyFn, Cache: cache, TTL: ttl})
+	}
+	return f
+}
+
+// SetCacheApply sets how the effect of the last node on the data is saved to its cache and applied again on a hit
+func (f *FlowEngine) SetCacheApply(save ICacheSaveFunc, apply ICacheApplyFunc) *FlowEngine {
+	if len(f.nodes) != 0 && f.nodes[len(f.nodes)-1].GetCache() != nil {
+		f.nodes[len(f.nodes)-1].GetCache().Save = save
+		f.nodes[len(f.nodes)-1].GetCache().Apply = apply
+	}
+	return f
+}
+
+func (f *FlowEngine) SetNote(note string) *FlowEngine {
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetNote(note)
+	}
+	return f
+}
+
+func (f *FlowEngine) SetBeginLogger(logger INodeBeginLogger) *FlowEngine {
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetBeginLogger(logger)
+	}
+	return f
+}
+
+func (f *FlowEngine) SetEndLogger(logger INodeEndLogger) *FlowEngine {
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].SetEndLogger(logger)
+	}
+	return f
+}
+
+func (f *FlowEngine) SetGlobalBeginLogger(logger INodeBeginLogger) *FlowEngine {
+	for _, note := range f.nodes {
+		if note.GetBeginLogger() == nil {
+			note.SetBeginLogger(logger)
+		}
+	}
+	return f
+}
+
+func (f *FlowEngine) SetGlobalEndLogger(logger INodeEndLogger) *FlowEngine {
+	for _, note := range f.nodes {
+		if note.GetEndLogger() == nil {
+			note.SetEndLogger(logger)
+		}
+	}
+	return f
+}
+
+func (f *FlowEngine) OnFail(functor IOnFailFunc) *FlowEngine {
+	f.onFailFunc = functor
+	return f
+}
+
+// OnStatus registers a handler of the failures with the status code. The handlers registered by OnStatus,
+// OnStatusRange and OnError are matched in the order of registration, the first matching one runs instead of OnFail.
+func (f *FlowEngine) OnStatus(code int64, functor IOnFailFunc) *FlowEngine {
+	f.failHandlers = append(f.failHandlers, &failHandler{match: MatchStatusCode(code), handler: functor})
+	return f
+}
+
+// OnStatusRange registers a handler of the failures whose status code is between lo and hi, both included
+func (f *FlowEngine) OnStatusRange(lo int64, hi int64, functor IOnFailFunc) *FlowEngine {
+	f.failHandlers = append(f.failHandlers, &failHandler{match: MatchStatusRange(lo, hi), handler: functor})
+	return f
+}
+
+// OnError registers a handler of the failures whose error is target according to errors.Is
+func (f *FlowEngine) OnError(target error, functor IOnFailFunc) *FlowEngine {
+	f.failHandlers = append(f.failHandlers, &failHandler{match: MatchError(target), handler: functor})
+	return f
+}
+
+func (f *FlowEngine) OnSuccess(functor IOnSuccessFunc) *FlowEngine {
+	f.onSuccessFunc = functor
+	return f
+}
+
+// SetCheckpointStore makes the flow save a checkpoint after every node once SetFlowID or Resume gives it an ID
+func (f *FlowEngine) SetCheckpointStore(store ICheckpointStore) *FlowEngine {
+	f.checkpointer.store = store
+	return f
+}
+
+func (f *FlowEngine) SetFlowID(flowID string) *FlowEngine {
+	f.checkpointer.flowID = flowID
+	return f
+}
+
+// SetRegistry sets the registry used to look up the functors and conditions given by name to the following nodes
+func (f *FlowEngine) SetRegistry(registry *Registry) *FlowEngine {
+	f.registry = registry
+	return f
+}
+
+func (f *FlowEngine) getData() *_Data {
+	return f.data
+}
+
+func (f *FlowEngine) setData(data *_Data) {
+	f.data = data
+}
+
+func (f *FlowEngine) getResult() **_Result {
+	return f.result
+}
+
+func (f *FlowEngine) setResult(result **_Result) {
+	f.result = result
+}
+
+func (f *FlowEngine) getOnFailFunc() IOnFailFunc {
+	return f.onFailFunc
 }
 
 func (f *FlowEngine) setOnFailFunc(function IOnFailFunc) {
 	f.onFailFunc = function
 }
 
+func (f *FlowEngine) getFailHandlers() []*failHandler {
+	return f.failHandlers
+}
+
+func (f *FlowEngine) setFailHandlers(handlers []*failHandler) {
+	f.failHandlers = handlers
+}
+
 func (f *FlowEngine) getOnSuccessFunc() IOnSuccessFunc {
 	return f.onSuccessFunc
 }
@@ -995,7 +2070,25 @@ func (f *FlowEngine) getNodes() []IBasicFlowNode {
 	return f.nodes
 }
 
+func (f *FlowEngine) getRegistry() *Registry {
+	return f.registry
+}
+
+func (f *FlowEngine) setContext(ctx context.Context) {
+	f.ctx = ctx
+}
+
+func (f *FlowEngine) setClock(clock Clock) {
+	f.clock = clock
+}
+
+// setTape sets the tape of the flow and the position of its nodes in the flow which runs it as a sub-path
+func (f *FlowEngine) setTape(tape tape, position string) {
+	f.tape, f.position = tape, position
+}
+
 func (f *FlowEngine) Attach(parent IFlowEngine) {
+	f.attached = true
 	f.data = parent.getData()
 	f.result = parent.getResult()
 	if len(f.nodes) != 0 {
@@ -1010,6 +2103,7 @@ func (f *FlowEngine) Inherit(parent IFlowEngine) {
 	f.Attach(parent)
 	f.onFailFunc = parent.getOnFailFunc()
 	f.onSuccessFunc = parent.getOnSuccessFunc()
+	f.failHandlers = append([]*failHandler(nil), parent.getFailHandlers()...)
 }
 
 //END FlowEngine
@@ -1023,6 +2117,7 @@ type ElseFlowEngine struct {
 	invoker       *FlowEngine
 	onFailFunc    IOnFailFunc
 	onSuccessFunc IOnSuccessFunc
+	failHandlers  []*failHandler
 }
 
 func NewElseFlowEngine(data **_Data, invoker *FlowEngine, result **_Result, nodes *[]IBasicFlowNode) *ElseFlowEngine {
@@ -1037,6 +2132,7 @@ func NewElseFlowEngine(data **_Data, invoker *FlowEngine, result **_Result, node
 
 func (e *ElseFlowEngine) Prepare(input _PrepareInput, prepareFunc ...IPrepareFunc) *FlowEngine {
 	node := NewPrepareNode(*e.data, e.result, input, prepareFunc...)
+	node.SetRegistry(e.invoker.registry)
 	if len(*e.nodes) != 0 {
 		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
 	}
@@ -1046,6 +2142,7 @@ func (e *ElseFlowEngine) Prepare(input _PrepareInput, prepareFunc ...IPrepareFun
 
 func (e *ElseFlowEngine) Do(functors ...ICallable) *FlowEngine {
 	node := NewNormalNode(*e.data, e.result, functors...)
+	node.SetRegistry(e.invoker.registry)
 	if len(*e.nodes) != 0 {
 		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
 	}
@@ -1055,6 +2152,7 @@ func (e *ElseFlowEngine) Do(functors ...ICallable) *FlowEngine {
 
 func (e *ElseFlowEngine) For(times int, functors ...ICallable) *FlowEngine {
 	node := NewForNode(times, *e.data, e.result, functors...)
+	node.SetRegistry(e.invoker.registry)
 	if len(*e.nodes) != 0 {
 		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
 	}
@@ -1064,6 +2162,47 @@ func (e *ElseFlowEngine) For(times int, functors ...ICallable) *FlowEngine {
 
 func (e *ElseFlowEngine) Parallel(functors ...ICallable) *FlowEngine {
 	node := NewParallelNode(*e.data, e.result, functors...)
+	node.SetRegistry(e.invoker.registry)
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
+	}
+	*e.nodes = append(*e.nodes, node)
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) Race(functors ...ICallable) *FlowEngine {
+	node := NewRaceNode(*e.data, e.result, functors...)
+	node.SetRegistry(e.invoker.registry)
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
+	}
+	*e.nodes = append(*e.nodes, node)
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) RaceSubPath(subPaths ...IFlowEngine) *FlowEngine {
+	node := NewRaceSubPathNode(*e.data, e.result, subPaths...)
+	node.SetRegistry(e.invoker.registry)
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
+	}
+	*e.nodes = append(*e.nodes, node)
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) Quorum(needed int, functors ...ICallable) *FlowEngine {
+	node := NewQuorumNode(needed, *e.data, e.result, functors...)
+	node.SetRegistry(e.invoker.registry)
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
+	}
+	*e.nodes = append(*e.nodes, node)
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) WaitForSignal(name string, timeout time.Duration) *FlowEngine {
+	node := NewSignalNode(name, timeout, *e.data, e.result)
+	node.SetRegistry(e.invoker.registry)
 	if len(*e.nodes) != 0 {
 		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
 	}
@@ -1073,6 +2212,7 @@ func (e *ElseFlowEngine) Parallel(functors ...ICallable) *FlowEngine {
 
 func (e *ElseFlowEngine) If(condition IBoolFunc, functors ...ICallable) *ElseFlowEngine {
 	node := NewIfNode(*e.data, e.result, condition, functors...)
+	node.SetRegistry(e.invoker.registry)
 	if len(*e.nodes) != 0 {
 		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
 	}
@@ -1082,6 +2222,7 @@ func (e *ElseFlowEngine) If(condition IBoolFunc, functors ...ICallable) *ElseFlo
 
 func (e *ElseFlowEngine) ElseIf(condition IBoolFunc, functors ...ICallable) *ElseFlowEngine {
 	node := NewElseIfNode(*e.data, e.result, condition, functors...)
+	node.SetRegistry(e.invoker.registry)
 	(*e.nodes)[len(*e.nodes)-1].SetNext(node)
 	*e.nodes = append(*e.nodes, node)
 	return e
@@ -1089,6 +2230,7 @@ func (e *ElseFlowEngine) ElseIf(condition IBoolFunc, functors ...ICallable) *Els
 
 func (e *ElseFlowEngine) Else(functors ...ICallable) *FlowEngine {
 	node := NewElseNode(*e.data, e.result, functors...)
+	node.SetRegistry(e.invoker.registry)
 	(*e.nodes)[len(*e.nodes)-1].SetNext(node)
 	*e.nodes = append(*e.nodes, node)
 	return e.invoker
@@ -1096,6 +2238,7 @@ func (e *ElseFlowEngine) Else(functors ...ICallable) *FlowEngine {
 
 func (e *ElseFlowEngine) IfSubPath(condition IBoolFunc, subPath IFlowEngine) *ElseFlowEngine {
 	node := NewIfSubPathNode(condition, subPath, e)
+	node.SetRegistry(e.invoker.registry)
 	if len(*e.nodes) != 0 {
 		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
 	}
@@ -1105,6 +2248,7 @@ func (e *ElseFlowEngine) IfSubPath(condition IBoolFunc, subPath IFlowEngine) *El
 
 func (e *ElseFlowEngine) ElseIfSubPath(condition IBoolFunc, subPath IFlowEngine) *ElseFlowEngine {
 	node := NewElseIfSubPathNode(condition, subPath, e)
+	node.SetRegistry(e.invoker.registry)
 	(*e.nodes)[len(*e.nodes)-1].SetNext(node)
 	*e.nodes = append(*e.nodes, node)
 	return e
@@ -1112,28 +2256,185 @@ func (e *ElseFlowEngine) ElseIfSubPath(condition IBoolFunc, subPath IFlowEngine)
 
 func (e *ElseFlowEngine) ElseSubPath(subPath IFlowEngine) *FlowEngine {
 	node := NewElseSubPathNode(subPath, e)
+	node.SetRegistry(e.invoker.registry)
 	(*e.nodes)[len(*e.nodes)-1].SetNext(node)
 	*e.nodes = append(*e.nodes, node)
 	return e.invoker
 }
 
+func (e *ElseFlowEngine) Finally(functors ...ICallable) *FlowEngine {
+	node := NewFinallyNode(*e.data, e.result, functors...)
+	node.SetRegistry(e.invoker.registry)
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
+	}
+	*e.nodes = append(*e.nodes, node)
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) FinallySubPath(subPath IFlowEngine) *FlowEngine {
+	node := NewFinallySubPathNode(subPath, e)
+	node.SetRegistry(e.invoker.registry)
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
+	}
+	*e.nodes = append(*e.nodes, node)
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) TrySubPath(subPath IFlowEngine) *FlowEngine {
+	node := NewTrySubPathNode(subPath, e)
+	node.SetRegistry(e.invoker.registry)
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
+	}
+	*e.nodes = append(*e.nodes, node)
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) Catch(match ICatchMatcher, handler ICatchFunc) *FlowEngine {
+	node := NewCatchNode(*e.data, e.result, match, handler)
+	node.SetRegistry(e.invoker.registry)
+	if len(*e.nodes) != 0 {
+		scopeCatch(node, (*e.nodes)[len(*e.nodes)-1])
+		(*e.nodes)[len(*e.nodes)-1].SetNext(node)
+	}
+	*e.nodes = append(*e.nodes, node)
+	return e.invoker
+}
+
 func (e *ElseFlowEngine) Wait() *_Result {
-	for _, node := range *e.nodes {
-		node.Run()
+	resetNodeStates(e)
+	return e.run(0)
+}
+
+func (e *ElseFlowEngine) Resume(flowID string) *_Result {
+	resetNodeStates(e)
+	*e.result = new(_Result)
+	e.invoker.checkpointer.flowID = flowID
+	start, err := e.invoker.checkpointer.restore(*e.nodes, *e.data)
+	if err != nil {
+		*e.result = &_Result{Err: err}
+	}
+	return e.run(start)
+}
+
+func (e *ElseFlowEngine) run(start int) *_Result {
+	if e.invoker.tape != nil && !e.invoker.attached {
+		e.invoker.tape.begin(*e.data, e.result)
+	}
+	returned := returnedBefore(*e.nodes, start)
+	for index := start; index < len(*e.nodes); index++ {
+		e.invoker.handle.enter(index, (*e.nodes)[index])
+		cancelled(e.invoker.ctx, e.result)
+		(*e.nodes)[index].SetClock(e.invoker.clock)
+		(*e.nodes)[index].setTape(e.invoker.tape, nodePosition(e.invoker.position, index))
+		for subIndex, subPath := range subPathsOf((*e.nodes)[index]) {
+			subPath.setContext(e.invoker.ctx)
+			subPath.setClock(e.invoker.clock)
+			subPath.setTape(e.invoker.tape, subPathPosition(e.invoker.position, index, subIndex))
+		}
+		if returned && !runsAfterReturn((*e.nodes)[index]) {
+			(*e.nodes)[index].SetState(SkippedNodeState)
+		} else {
+			e.invoker.checkpointer.suspend(*e.nodes, index, *e.data, e.result)
+			runNode((*e.nodes)[index], *e.data, *e.result)
+		}
+		returned = returned || (*e.nodes)[index].GetState() == ReturnedNodeState
+		e.invoker.checkpointer.save(*e.nodes, index, *e.data, e.result)
+	}
+	e.invoker.checkpointer.finish(*e.result)
+	if !e.invoker.attached {
+		e.invoker.compensations = compensate(e, *e.data, e.result)
+		if len(e.invoker.compensations) != 0 {
+			e.invoker.checkpointer.discard()
+		}
 	}
 	if e.onSuccessFunc != nil {
 		if (*e.result).Err == nil && (*e.result).StatusCode == 0 {
 			e.onSuccessFunc(*e.data, *e.result)
 		}
 	}
-	if e.onFailFunc != nil {
+	if onFail := failHandlerFor(e.failHandlers, e.onFailFunc, *e.result); onFail != nil {
 		if (*e.result).Err != nil || (*e.result).StatusCode != 0 {
-			e.onFailFunc(*e.data, *e.result)
+			onFail(*e.data, *e.result)
 		}
 	}
+	if e.invoker.coverage != nil && !e.invoker.attached {
+		e.invoker.coverage.recordRun(e.invoker.coverageName, e)
+	}
+	if e.invoker.tape != nil && !e.invoker.attached {
+		e.invoker.tape.end(*e.result)
+	}
 	return *e.result
 }
 
+func (e *ElseFlowEngine) Compensate(functor ICallable) *ElseFlowEngine {
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetCompensation(functor)
+	}
+	return e
+}
+
+func (e *ElseFlowEngine) SetRateLimit(limiter IRateLimiter) *ElseFlowEngine {
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetRateLimit(limiter)
+	}
+	return e
+}
+
+func (e *ElseFlowEngine) SetCircuitBreaker(breaker *CircuitBreaker) *ElseFlowEngine {
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetCircuitBreaker(breaker)
+	}
+	return e
+}
+
+func (e *ElseFlowEngine) SetClock(clock Clock) *ElseFlowEngine {
+	e.invoker.clock = clock
+	return e
+}
+
+func (e *ElseFlowEngine) SetSignalApply(apply ISignalFunc) *ElseFlowEngine {
+	if len(*e.nodes) != 0 {
+		if node, ok := (*e.nodes)[len(*e.nodes)-1].(*SignalNode); ok {
+			node.Apply = apply
+		}
+	}
+	return e
+}
+
+func (e *ElseFlowEngine) SetQuorumCancel(cancel bool) *ElseFlowEngine {
+	if len(*e.nodes) != 0 {
+		if node, ok := (*e.nodes)[len(*e.nodes)-1].(*QuorumNode); ok {
+			node.CancelRemaining = cancel
+		}
+	}
+	return e
+}
+
+func (e *ElseFlowEngine) SetHedge(delay time.Duration, maxExtra int) *ElseFlowEngine {
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetHedge(NewHedge(delay, maxExtra))
+	}
+	return e
+}
+
+func (e *ElseFlowEngine) SetCache(keyFn ICacheKeyFunc, cache ICache, ttl time.Duration) *ElseFlowEngine {
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].SetCache(&NodeCache{Key: keyFn, Cache: cache, TTL: ttl})
+	}
+	return e
+}
+
+func (e *ElseFlowEngine) SetCacheApply(save ICacheSaveFunc, apply ICacheApplyFunc) *ElseFlowEngine {
+	if len(*e.nodes) != 0 && (*e.nodes)[len(*e.nodes)-1].GetCache() != nil {
+		(*e.nodes)[len(*e.nodes)-1].GetCache().Save = save
+		(*e.nodes)[len(*e.nodes)-1].GetCache().Apply = apply
+	}
+	return e
+}
+
 func (e *ElseFlowEngine) SetNote(note string) *ElseFlowEngine {
 	if len(*e.nodes) != 0 {
 		(*e.nodes)[len(*e.nodes)-1].SetNote(note)
@@ -1178,11 +2479,41 @@ func (e *ElseFlowEngine) OnFail(functor IOnFailFunc) *ElseFlowEngine {
 	return e
 }
 
+func (e *ElseFlowEngine) OnStatus(code int64, functor IOnFailFunc) *ElseFlowEngine {
+	e.failHandlers = append(e.failHandlers, &failHandler{match: MatchStatusCode(code), handler: functor})
+	return e
+}
+
+func (e *ElseFlowEngine) OnStatusRange(lo int64, hi int64, functor IOnFailFunc) *ElseFlowEngine {
+	e.failHandlers = append(e.failHandlers, &failHandler{match: MatchStatusRange(lo, hi), handler: functor})
+	return e
+}
+
+func (e *ElseFlowEngine) OnError(target error, functor IOnFailFunc) *ElseFlowEngine {
+	e.failHandlers = append(e.failHandlers, &failHandler{match: MatchError(target), handler: functor})
+	return e
+}
+
 func (e *ElseFlowEngine) OnSuccess(functor IOnSuccessFunc) *ElseFlowEngine {
 	e.onSuccessFunc = functor
 	return e
 }
 
+func (e *ElseFlowEngine) SetCheckpointStore(store ICheckpointStore) *ElseFlowEngine {
+	e.invoker.checkpointer.store = store
+	return e
+}
+
+func (e *ElseFlowEngine) SetFlowID(flowID string) *ElseFlowEngine {
+	e.invoker.checkpointer.flowID = flowID
+	return e
+}
+
+func (e *ElseFlowEngine) SetRegistry(registry *Registry) *ElseFlowEngine {
+	e.invoker.registry = registry
+	return e
+}
+
 func (e *ElseFlowEngine) getData() *_Data {
 	if e.data != nil {
 		return *e.data
@@ -1210,6 +2541,14 @@ func (e *ElseFlowEngine) setOnFailFunc(function IOnFailFunc) {
 	e.onFailFunc = function
 }
 
+func (e *ElseFlowEngine) getFailHandlers() []*failHandler {
+	return e.failHandlers
+}
+
+func (e *ElseFlowEngine) setFailHandlers(handlers []*failHandler) {
+	e.failHandlers = handlers
+}
+
 func (e *ElseFlowEngine) getOnSuccessFunc() IOnSuccessFunc {
 	return e.onSuccessFunc
 }
@@ -1225,7 +2564,24 @@ func (e *ElseFlowEngine) getNodes() []IBasicFlowNode {
 	return nil
 }
 
+func (e *ElseFlowEngine) getRegistry() *Registry {
+	return e.invoker.registry
+}
+
+func (e *ElseFlowEngine) setContext(ctx context.Context) {
+	e.invoker.ctx = ctx
+}
+
+func (e *ElseFlowEngine) setClock(clock Clock) {
+	e.invoker.clock = clock
+}
+
+func (e *ElseFlowEngine) setTape(tape tape, position string) {
+	e.invoker.setTape(tape, position)
+}
+
 func (e *ElseFlowEngine) Attach(parent IFlowEngine) {
+	e.invoker.attached = true
 	*e.data = parent.getData()
 	e.result = parent.getResult()
 	if len(*e.nodes) != 0 {
@@ -1240,6 +2596,7 @@ func (e *ElseFlowEngine) Inherit(parent IFlowEngine) {
 	e.Attach(parent)
 	e.onFailFunc = parent.getOnFailFunc()
 	e.onSuccessFunc = parent.getOnSuccessFunc()
+	e.failHandlers = append([]*failHandler(nil), parent.getFailHandlers()...)
 }
 
 //END ElseFlowEngine
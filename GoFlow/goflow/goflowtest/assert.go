@@ -0,0 +1,105 @@
+//go:build goflowtest
+// +build goflowtest
+
+package goflowtest
+
+import (
+	"strings"
+
+	"goflow/goflow"
+)
+
+// TestingT is the part of testing.T the assertions use
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// IFlow is a flow whose nodes can be found by their notes, FlowEngine and ElseFlowEngine implement it
+type IFlow interface {
+	Plan() *goflow.Plan
+}
+
+func names(calls []Call) string {
+	res := make([]string, 0, len(calls))
+	for _, call := range calls {
+		res = append(res, call.Name)
+	}
+	return strings.Join(res, ", ")
+}
+
+// AssertCalledInOrder checks that the mocks were called in this order, other calls may happen in between. The mocks
+// must come from the same recorder.
+func AssertCalledInOrder(t TestingT, mocks ...IMock) bool {
+	t.Helper()
+	if len(mocks) == 0 {
+		return true
+	}
+	calls := mocks[0].recorder().Calls()
+	next := 0
+	for _, call := range calls {
+		if next < len(mocks) && call.mock == mocks[next] {
+			next++
+		}
+	}
+	if next < len(mocks) {
+		t.Errorf("%s was not called in order, the calls were: [%s]", mocks[next].Name(), names(calls))
+		return false
+	}
+	return true
+}
+
+func AssertNotCalled(t TestingT, mocks ...IMock) bool {
+	t.Helper()
+	ok := true
+	for _, mock := range mocks {
+		if calls := mock.Calls(); len(calls) != 0 {
+			t.Errorf("%s was called %d times", mock.Name(), len(calls))
+			ok = false
+		}
+	}
+	return ok
+}
+
+// nodesWithNote returns the nodes of the flow and its sub-paths whose note is note, set by SetNote
+func nodesWithNote(t TestingT, flow IFlow, note string) []goflow.IBasicFlowNode {
+	t.Helper()
+	var nodes []goflow.IBasicFlowNode
+	for _, step := range flow.Plan().Steps {
+		if step.Note == note {
+			nodes = append(nodes, step.Node)
+		}
+	}
+	if len(nodes) == 0 {
+		t.Errorf("no node has the note %q", note)
+	}
+	return nodes
+}
+
+// AssertBranchTaken checks that the branch with the note, such as an If, an Else or an IfSubPath, was taken in the
+// last run of the flow
+func AssertBranchTaken(t TestingT, flow IFlow, note string) bool {
+	t.Helper()
+	nodes := nodesWithNote(t, flow, note)
+	for _, node := range nodes {
+		switch node.GetState() {
+		case goflow.NotRunNodeState, goflow.SkippedNodeState, goflow.NotTakenNodeState:
+			t.Errorf("branch %q was not taken, its state is %s", note, node.GetState())
+			return false
+		}
+	}
+	return len(nodes) != 0
+}
+
+// AssertSkipped checks that the nodes with the note were skipped in the last run of the flow
+func AssertSkipped(t TestingT, flow IFlow, note string) bool {
+	t.Helper()
+	nodes := nodesWithNote(t, flow, note)
+	for _, node := range nodes {
+		if node.GetState() != goflow.SkippedNodeState {
+			t.Errorf("node %q was not skipped, its state is %s", note, node.GetState())
+			return false
+		}
+	}
+	return len(nodes) != 0
+}
@@ -0,0 +1,141 @@
+//go:build goflowtest
+// +build goflowtest
+
+package goflowtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"goflow/goflow"
+)
+
+// FakeClock is a goflow.Clock whose time only moves by Advance, so that timeouts, backoffs and schedules are tested
+// without waiting. The timers fire during Advance, in the order of their deadlines.
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{}
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *FakeClock) NewTimer(d time.Duration) goflow.Timer {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	timer := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	f.start(timer, d)
+	return timer
+}
+
+// start must be called with the mutex held
+func (f *FakeClock) start(timer *fakeTimer, d time.Duration) {
+	timer.deadline = f.now.Add(d)
+	if d <= 0 {
+		timer.fire(f.now)
+		return
+	}
+	f.timers = append(f.timers, timer)
+	f.signal()
+}
+
+// stop must be called with the mutex held
+func (f *FakeClock) stop(timer *fakeTimer) bool {
+	for index, current := range f.timers {
+		if current == timer {
+			f.timers = append(f.timers[:index], f.timers[index+1:]...)
+			f.signal()
+			return true
+		}
+	}
+	return false
+}
+
+// signal wakes up WaitForTimers, it must be called with the mutex held
+func (f *FakeClock) signal() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// Advance moves the time forward and fires the timers whose deadline has come
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+	sort.SliceStable(f.timers, func(i, j int) bool {
+		return f.timers[i].deadline.Before(f.timers[j].deadline)
+	})
+	remaining := f.timers[:0]
+	for _, timer := range f.timers {
+		if timer.deadline.After(f.now) {
+			remaining = append(remaining, timer)
+		} else {
+			timer.fire(f.now)
+		}
+	}
+	f.timers = remaining
+	f.signal()
+}
+
+// Timers returns the number of timers waiting to fire
+func (f *FakeClock) Timers() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.timers)
+}
+
+// WaitForTimers blocks until at least n timers are waiting to fire, e.g. until the code under test has started waiting
+// before the test advances the clock
+func (f *FakeClock) WaitForTimers(n int) {
+	for {
+		f.mutex.Lock()
+		count, changed := len(f.timers), f.changed
+		f.mutex.Unlock()
+		if count >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+// fire sends the time without blocking, like time.Timer the channel holds one value
+func (t *fakeTimer) fire(now time.Time) {
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	return t.clock.stop(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	active := t.clock.stop(t)
+	t.clock.start(t, d)
+	return active
+}
@@ -0,0 +1,98 @@
+//go:build goflowtest
+// +build goflowtest
+
+package goflowtest
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func fired(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case now := <-c:
+		return now, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeClockOnlyMovesByAdvance(t *testing.T) {
+	clock := NewFakeClock(start)
+	if !clock.Now().Equal(start) {
+		t.Errorf("expected %v, got %v", start, clock.Now())
+	}
+	clock.Advance(time.Minute)
+	if !clock.Now().Equal(start.Add(time.Minute)) {
+		t.Errorf("expected the time to move by a minute, got %v", clock.Now())
+	}
+}
+
+func TestFakeClockFiresTimersInDeadlineOrder(t *testing.T) {
+	clock := NewFakeClock(start)
+	late := clock.NewTimer(2 * time.Minute)
+	early := clock.NewTimer(time.Minute)
+	if clock.Timers() != 2 {
+		t.Fatalf("expected 2 waiting timers, got %d", clock.Timers())
+	}
+
+	clock.Advance(time.Minute)
+	if now, ok := fired(early.C()); !ok || !now.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected the early timer to fire at the new time, got %v", now)
+	}
+	if _, ok := fired(late.C()); ok {
+		t.Error("expected the late timer to wait")
+	}
+	clock.Advance(time.Minute)
+	if _, ok := fired(late.C()); !ok {
+		t.Error("expected the late timer to fire")
+	}
+	if clock.Timers() != 0 {
+		t.Errorf("expected no waiting timer, got %d", clock.Timers())
+	}
+
+	if _, ok := fired(clock.NewTimer(0).C()); !ok {
+		t.Error("expected a timer without delay to fire at once")
+	}
+}
+
+func TestFakeClockStopAndReset(t *testing.T) {
+	clock := NewFakeClock(start)
+	timer := clock.NewTimer(time.Minute)
+	if !timer.Stop() || timer.Stop() {
+		t.Error("expected Stop to report whether the timer was waiting")
+	}
+	clock.Advance(time.Minute)
+	if _, ok := fired(timer.C()); ok {
+		t.Error("expected a stopped timer not to fire")
+	}
+
+	if timer.Reset(time.Minute) {
+		t.Error("expected Reset of a stopped timer to report it was not waiting")
+	}
+	if !timer.Reset(2 * time.Minute) {
+		t.Error("expected Reset of a waiting timer to report it was waiting")
+	}
+	clock.Advance(time.Minute)
+	if _, ok := fired(timer.C()); ok {
+		t.Error("expected the timer to wait for its new deadline")
+	}
+	clock.Advance(time.Minute)
+	if _, ok := fired(timer.C()); !ok {
+		t.Error("expected the timer to fire on its new deadline")
+	}
+}
+
+func TestFakeClockWaitForTimers(t *testing.T) {
+	clock := NewFakeClock(start)
+	done := make(chan struct{})
+	go func() {
+		<-clock.NewTimer(time.Minute).C()
+		close(done)
+	}()
+	clock.WaitForTimers(1)
+	clock.Advance(time.Minute)
+	<-done
+}
@@ -0,0 +1,284 @@
+//go:build goflowtest
+// +build goflowtest
+
+// Package goflowtest helps testing flows with mock functors and conditions which record their calls, and with
+// assertions about the calls and the nodes of a flow run. Since they use the types of the flow, its files are only
+// built once generated by flow.py with --import, which drops their goflowtest build constraint.
+package goflowtest
+
+import (
+	"sync"
+	"time"
+
+	"goflow/goflow"
+)
+
+// Call is one call of a mock. Index is the position of the call among all the calls of the recorder.
+type Call struct {
+	Index int
+	Name  string
+	Data  *goflow._Data
+	// Input is the input of a prepare function
+	Input *goflow._PrepareInput
+	// Result is what a functor returned, and Value what a condition returned
+	Result *goflow._Result
+	Value  bool
+
+	mock IMock
+}
+
+// IMock is a mock functor or condition
+type IMock interface {
+	Name() string
+	Calls() []Call
+	recorder() *Recorder
+}
+
+// Recorder keeps the calls of its mocks in the order they happened
+type Recorder struct {
+	mutex sync.Mutex
+	calls []Call
+	clock goflow.Clock
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{clock: goflow.SystemClock}
+}
+
+// SetClock sets the clock the delays of the mocks are waited on, which should be the clock given to the flow by
+// SetClock so that a FakeClock drives them as well. It is goflow.SystemClock by default.
+func (r *Recorder) SetClock(clock goflow.Clock) *Recorder {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.clock = clock
+	return r
+}
+
+func (r *Recorder) getClock() goflow.Clock {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.clock
+}
+
+func (r *Recorder) record(call Call) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	call.Index = len(r.calls)
+	r.calls = append(r.calls, call)
+}
+
+func (r *Recorder) Calls() []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+func (r *Recorder) callsOf(mock IMock) []Call {
+	var calls []Call
+	for _, call := range r.Calls() {
+		if call.mock == mock {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the calls, e.g. between the runs of a table test
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = nil
+}
+
+// step is one scripted call. The steps are used in order and the last one is repeated.
+type step struct {
+	result *goflow._Result
+	value  bool
+	panic  interface{}
+	delay  time.Duration
+}
+
+type script struct {
+	mutex sync.Mutex
+	steps []*step
+	next  int
+}
+
+func (s *script) add(step *step) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.steps = append(s.steps, step)
+}
+
+// last returns the last scripted step, adding a default one if there is none
+func (s *script) last() *step {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.steps) == 0 {
+		s.steps = append(s.steps, &step{})
+	}
+	return s.steps[len(s.steps)-1]
+}
+
+func (s *script) take() *step {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.steps) == 0 {
+		return &step{}
+	}
+	current := s.steps[s.next]
+	if s.next < len(s.steps)-1 {
+		s.next++
+	}
+	return current
+}
+
+// play waits on the clock and panics as scripted, or returns the step
+func (s *step) play(clock goflow.Clock) *step {
+	if s.delay > 0 {
+		timer := clock.NewTimer(s.delay)
+		<-timer.C()
+	}
+	if s.panic != nil {
+		panic(s.panic)
+	}
+	return s
+}
+
+// MockFunctor is a functor returning scripted results, successful by default
+type MockFunctor struct {
+	name   string
+	rec    *Recorder
+	script script
+	effect func(_data *goflow._Data)
+}
+
+// Functor creates a mock functor recording its calls in the recorder
+func (r *Recorder) Functor(name string) *MockFunctor {
+	return &MockFunctor{name: name, rec: r}
+}
+
+func (m *MockFunctor) Name() string {
+	return m.name
+}
+
+func (m *MockFunctor) Calls() []Call {
+	return m.rec.callsOf(m)
+}
+
+func (m *MockFunctor) recorder() *Recorder {
+	return m.rec
+}
+
+// Return scripts the next call to return the result, nil meaning success
+func (m *MockFunctor) Return(result *goflow._Result) *MockFunctor {
+	m.script.add(&step{result: result})
+	return m
+}
+
+func (m *MockFunctor) ReturnSuccess() *MockFunctor {
+	return m.Return(nil)
+}
+
+func (m *MockFunctor) ReturnError(err error) *MockFunctor {
+	return m.Return(&goflow._Result{Err: err})
+}
+
+func (m *MockFunctor) ReturnStatus(code int64, msg string) *MockFunctor {
+	return m.Return(&goflow._Result{StatusCode: code, StatusMsg: msg})
+}
+
+// Panic scripts the next call to panic with the value
+func (m *MockFunctor) Panic(value interface{}) *MockFunctor {
+	m.script.add(&step{panic: value})
+	return m
+}
+
+// Delay makes the last scripted call wait before it returns, on the clock of the recorder
+func (m *MockFunctor) Delay(delay time.Duration) *MockFunctor {
+	m.script.last().delay = delay
+	return m
+}
+
+// SetEffect sets a change made to the data by every call, before it returns
+func (m *MockFunctor) SetEffect(effect func(_data *goflow._Data)) *MockFunctor {
+	m.effect = effect
+	return m
+}
+
+func (m *MockFunctor) call(_data *goflow._Data, input *goflow._PrepareInput) *goflow._Result {
+	current := m.script.take()
+	call := Call{Name: m.name, Data: _data, Input: input, Result: current.result, mock: m}
+	m.rec.record(call)
+	current.play(m.rec.getClock())
+	if m.effect != nil {
+		m.effect(_data)
+	}
+	return current.result
+}
+
+// Func is the functor to give to the flow
+func (m *MockFunctor) Func() goflow.ICallable {
+	return func(_data *goflow._Data) *goflow._Result {
+		return m.call(_data, nil)
+	}
+}
+
+// PrepareFunc is the mock used as a prepare function, the input is recorded in the calls
+func (m *MockFunctor) PrepareFunc() goflow.IPrepareFunc {
+	return func(_data *goflow._Data, input goflow._PrepareInput) *goflow._Result {
+		return m.call(_data, &input)
+	}
+}
+
+// MockCondition is a condition returning scripted values, false by default
+type MockCondition struct {
+	name   string
+	rec    *Recorder
+	script script
+}
+
+// Condition creates a mock condition recording its calls in the recorder
+func (r *Recorder) Condition(name string) *MockCondition {
+	return &MockCondition{name: name, rec: r}
+}
+
+func (m *MockCondition) Name() string {
+	return m.name
+}
+
+func (m *MockCondition) Calls() []Call {
+	return m.rec.callsOf(m)
+}
+
+func (m *MockCondition) recorder() *Recorder {
+	return m.rec
+}
+
+// Return scripts the values of the next calls, one call per value
+func (m *MockCondition) Return(values ...bool) *MockCondition {
+	for _, value := range values {
+		m.script.add(&step{value: value})
+	}
+	return m
+}
+
+func (m *MockCondition) Panic(value interface{}) *MockCondition {
+	m.script.add(&step{panic: value})
+	return m
+}
+
+// Delay makes the last scripted call wait before it returns, on the clock of the recorder
+func (m *MockCondition) Delay(delay time.Duration) *MockCondition {
+	m.script.last().delay = delay
+	return m
+}
+
+// Func is the condition to give to the flow
+func (m *MockCondition) Func() goflow.IBoolFunc {
+	return func(_data *goflow._Data) bool {
+		current := m.script.take()
+		m.rec.record(Call{Name: m.name, Data: _data, Value: current.value, mock: m})
+		return current.play(m.rec.getClock()).value
+	}
+}
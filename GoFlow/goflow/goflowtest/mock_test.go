@@ -0,0 +1,105 @@
+//go:build goflowtest
+// +build goflowtest
+
+package goflowtest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"goflow/goflow"
+)
+
+// fakeT keeps the failures of the assertions instead of failing the test
+type fakeT struct {
+	failures []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func TestMocksFollowTheirScript(t *testing.T) {
+	boom := errors.New("boom")
+	rec := NewRecorder()
+	first := rec.Functor("first").ReturnError(boom).ReturnSuccess()
+	vip := rec.Condition("vip").Return(false, true)
+
+	if result := first.Func()(nil); result == nil || result.Err != boom {
+		t.Errorf("expected the first call to fail, got %v", result)
+	}
+	if result := first.Func()(nil); result != nil {
+		t.Errorf("expected the second call to succeed, got %v", result)
+	}
+	if result := first.Func()(nil); result != nil {
+		t.Errorf("expected the last step to be repeated, got %v", result)
+	}
+	if vip.Func()(nil) || !vip.Func()(nil) {
+		t.Error("expected the condition to return its scripted values")
+	}
+	if calls := rec.Calls(); len(calls) != 5 || calls[3].Name != "vip" || calls[3].Index != 3 {
+		t.Errorf("expected the calls to be recorded in order, got %d calls", len(calls))
+	}
+	rec.Reset()
+	if len(first.Calls()) != 0 {
+		t.Error("expected Reset to forget the calls")
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	rec := NewRecorder()
+	first, second, other := rec.Functor("first"), rec.Functor("second"), rec.Functor("other")
+	vip := rec.Condition("vip").Return(true)
+	flow := goflow.NewFlow().Do(first.Func()).If(vip.Func(), second.Func()).SetNote("vip").
+		Else(other.Func()).SetNote("regular")
+	if result := flow.Wait(); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+
+	AssertCalledInOrder(t, first, vip, second)
+	AssertNotCalled(t, other)
+	AssertBranchTaken(t, flow, "vip")
+	AssertSkipped(t, flow, "regular")
+
+	fake := &fakeT{}
+	AssertCalledInOrder(fake, second, first)
+	AssertNotCalled(fake, first)
+	AssertBranchTaken(fake, flow, "regular")
+	AssertSkipped(fake, flow, "missing")
+	if len(fake.failures) != 4 {
+		t.Errorf("expected every wrong assertion to fail, got %v", fake.failures)
+	}
+}
+
+func TestDelayFollowsTheClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	rec := NewRecorder().SetClock(clock)
+	slow := rec.Functor("slow").ReturnSuccess().Delay(time.Hour)
+	flow := goflow.NewFlow().SetClock(clock).Do(slow.Func())
+
+	done := make(chan bool, 1)
+	go func() {
+		done <- flow.Wait().Err == nil
+	}()
+	clock.WaitForTimers(1)
+	clock.Advance(time.Hour)
+	if !<-done {
+		t.Error("expected the delayed call to succeed")
+	}
+}
+
+func TestMockPanics(t *testing.T) {
+	rec := NewRecorder()
+	broken := rec.Functor("broken").Panic("broken")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected the mock to panic")
+		}
+		AssertCalledInOrder(t, broken)
+	}()
+	broken.Func()(nil)
+}
@@ -0,0 +1,153 @@
+package goflow
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+)
+
+// Progress tells which node of the flow is running. NodeIndex counts the nodes of the flow itself, not the ones of
+// its sub-paths, and is -1 before the first node starts.
+type Progress struct {
+	NodeIndex int
+	Nodes     int
+	Note      string
+}
+
+// Handle follows a flow running in the background after Start
+type Handle struct {
+	done     chan struct{}
+	cancel   context.CancelFunc
+	mutex    sync.Mutex
+	result   *_Result
+	progress Progress
+	signals  map[string][]interface{}
+	// arrived is closed and replaced every time a signal arrives
+	arrived chan struct{}
+}
+
+func newHandle(cancel context.CancelFunc, nodes int) *Handle {
+	return &Handle{
+		done:     make(chan struct{}),
+		cancel:   cancel,
+		progress: Progress{NodeIndex: -1, Nodes: nodes},
+		signals:  make(map[string][]interface{}),
+		arrived:  make(chan struct{}),
+	}
+}
+
+// Done is closed once the flow has finished, including its OnSuccess or OnFail handler
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Result returns the result of the flow, or nil if it is still running
+func (h *Handle) Result() *_Result {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.result
+}
+
+// Cancel cancels the context of the flow. The node running at that moment completes, and the flow fails with the
+// error of the context before the next node.
+func (h *Handle) Cancel() {
+	h.cancel()
+}
+
+func (h *Handle) Progress() Progress {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.progress
+}
+
+func (h *Handle) enter(index int, node IBasicFlowNode) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.progress.NodeIndex, h.progress.Note = index, node.GetNote()
+}
+
+func (h *Handle) finish(result *_Result) {
+	h.mutex.Lock()
+	h.result = result
+	h.mutex.Unlock()
+	h.cancel()
+	close(h.done)
+}
+
+// startFlow runs wait in a new goroutine. A panic of the flow is turned into a failure with PanicHappened.
+func startFlow(handle *Handle, wait func() *_Result, reset func()) {
+	go func() {
+		result := &_Result{}
+		defer func() {
+			if a := recover(); a != nil {
+				result = &_Result{Err: NewPanicHappened(string(debug.Stack()))}
+			}
+			reset()
+			handle.finish(result)
+		}()
+		result = wait()
+	}()
+}
+
+// cancelled fails the flow with the error of its context once the context is done, unless it has failed already
+func cancelled(ctx context.Context, result **_Result) {
+	if ctx == nil || ctx.Err() == nil || (*result).Err != nil || (*result).StatusCode != 0 {
+		return
+	}
+	*result = &_Result{Err: ctx.Err()}
+}
+
+// Start runs the flow in the background with ctx, which is also given to the data by SetContext until the flow
+// finishes, when the context the data had before is put back. The context carries the handle, so that the
+// WaitForSignal nodes of the flow and its sub-paths receive its signals.
+func (f *FlowEngine) Start(ctx context.Context) *Handle {
+	return f.start(ctx, f.Wait)
+}
+
+// StartResume runs Resume in the background like Start, e.g. to send the signal a resumed flow is waiting for
+func (f *FlowEngine) StartResume(ctx context.Context, flowID string) *Handle {
+	return f.start(ctx, func() *_Result {
+		return f.Resume(flowID)
+	})
+}
+
+func (f *FlowEngine) start(ctx context.Context, wait func() *_Result) *Handle {
+	ctx, cancel := context.WithCancel(ctx)
+	handle := newHandle(cancel, len(f.nodes))
+	ctx = context.WithValue(ctx, handleKey{}, handle)
+	f.ctx, f.handle = ctx, handle
+	previous := GetContext(f.data)
+	SetContext(f.data, ctx)
+	startFlow(handle, wait, func() {
+		f.ctx, f.handle = nil, nil
+		SetContext(f.data, previous)
+	})
+	return handle
+}
+
+func (e *ElseFlowEngine) Start(ctx context.Context) *Handle {
+	return e.start(ctx, e.Wait)
+}
+
+func (e *ElseFlowEngine) StartResume(ctx context.Context, flowID string) *Handle {
+	return e.start(ctx, func() *_Result {
+		return e.Resume(flowID)
+	})
+}
+
+func (e *ElseFlowEngine) start(ctx context.Context, wait func() *_Result) *Handle {
+	ctx, cancel := context.WithCancel(ctx)
+	handle := newHandle(cancel, len(*e.nodes))
+	ctx = context.WithValue(ctx, handleKey{}, handle)
+	e.invoker.ctx, e.invoker.handle = ctx, handle
+	previous := GetContext(*e.data)
+	SetContext(*e.data, ctx)
+	startFlow(handle, wait, func() {
+		e.invoker.ctx, e.invoker.handle = nil, nil
+		SetContext(*e.data, previous)
+	})
+	return handle
+}
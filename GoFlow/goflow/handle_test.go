@@ -0,0 +1,83 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStartRunsFlowInBackground(t *testing.T) {
+	release := make(chan struct{})
+	flow := NewFlow().Do(mark("first")).SetNote("waiting").Do(func(_data *_Data) *_Result {
+		<-release
+		return nil
+	}).SetNote("blocked")
+
+	handle := flow.Start(context.Background())
+	for handle.Progress().NodeIndex != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if progress := handle.Progress(); progress.Note != "blocked" || progress.Nodes != 2 {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+	if handle.Result() != nil {
+		t.Error("expected no result while the flow runs")
+	}
+	close(release)
+	<-handle.Done()
+	if result := handle.Result(); result == nil || result.Err != nil {
+		t.Errorf("expected the flow to succeed, got %+v", result)
+	}
+}
+
+func TestCancelStopsBeforeNextNode(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	flow := NewFlow().Do(func(_data *_Data) *_Result {
+		close(started)
+		<-release
+		return nil
+	}).Do(mark("skipped")).Finally(mark("finally"))
+
+	handle := flow.Start(context.Background())
+	<-started
+	handle.Cancel()
+	close(release)
+	<-handle.Done()
+	if result := handle.Result(); result.Err != context.Canceled {
+		t.Errorf("expected the flow to be cancelled, got %v", result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "finally;" {
+		t.Errorf("expected only the Finally to run, got %s", data)
+	}
+}
+
+func TestStartRecoversPanic(t *testing.T) {
+	handle := NewFlow().Do(func(_data *_Data) *_Result { panic("broken") }).Start(context.Background())
+	<-handle.Done()
+	if _, ok := handle.Result().Err.(*PanicHappened); !ok {
+		t.Errorf("expected PanicHappened, got %v", handle.Result().Err)
+	}
+}
+
+func TestStartRestoresContextOfData(t *testing.T) {
+	flow := NewFlow().Race(func(_data *_Data) *_Result {
+		if err := GetContext(_data).Err(); err != nil {
+			return &_Result{Err: err}
+		}
+		return nil
+	})
+	<-flow.Start(context.Background()).Done()
+	if ctx := GetContext(flow.getData()); ctx != nil {
+		t.Fatalf("expected the context of the data to be put back, got %v", ctx)
+	}
+	if result := flow.Wait(); result.Err != nil {
+		t.Errorf("expected a later Wait to run without the context of Start, got %v", result.Err)
+	}
+
+	chain := NewFlow().If(always, succeed)
+	<-chain.Start(context.Background()).Done()
+	if ctx := GetContext(chain.getData()); ctx != nil {
+		t.Errorf("expected the context of the data to be put back, got %v", ctx)
+	}
+}
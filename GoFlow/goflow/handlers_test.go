@@ -0,0 +1,57 @@
+package goflow
+
+import (
+	"fmt"
+	"testing"
+)
+
+func status(code int64) ICallable {
+	return func(_data *_Data) *_Result {
+		return &_Result{StatusCode: code}
+	}
+}
+
+func TestFailHandlersRouteByStatus(t *testing.T) {
+	var handled string
+	handler := func(name string) IOnFailFunc {
+		return func(_data *_Data, _result *_Result) {
+			handled = name
+		}
+	}
+	build := func(functor ICallable) *Flow {
+		return NewFlow().Do(functor).OnFail(handler("fail")).
+			OnStatus(404, handler("not-found")).
+			OnStatusRange(400, 499, handler("client")).
+			OnStatusRange(400, 599, handler("any")).
+			OnError(errTest, handler("error"))
+	}
+	cases := []struct {
+		functor ICallable
+		handled string
+	}{
+		{status(404), "not-found"},
+		{status(403), "client"},
+		{status(503), "any"},
+		{status(302), "fail"},
+		{fail, "error"},
+		{func(_data *_Data) *_Result { return &_Result{Err: fmt.Errorf("wrapped: %w", errTest)} }, "error"},
+		{succeed, ""},
+	}
+	for index, c := range cases {
+		handled = ""
+		build(c.functor).Wait()
+		if handled != c.handled {
+			t.Errorf("case %d: expected %q, got %q", index, c.handled, handled)
+		}
+	}
+}
+
+func TestFailHandlersOfElseFlowEngine(t *testing.T) {
+	handled := 0
+	NewFlow().If(always, status(500)).OnStatus(500, func(_data *_Data, _result *_Result) {
+		handled++
+	}).Wait()
+	if handled != 1 {
+		t.Errorf("expected the handler to run once, got %d", handled)
+	}
+}
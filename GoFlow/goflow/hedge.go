@@ -0,0 +1,93 @@
+package goflow
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+)
+
+// Hedge calls a functor again, concurrently, when it has not finished after Delay, up to MaxExtra more times with
+// Delay between them. The first attempt which succeeds wins and the context of the others is cancelled. Every attempt
+// runs on a copy of the data, and the copy of the winner is put back into the data, which is left as it was if all the
+// attempts fail, like in a Race. The copies share what the pointers, maps and slices of the data refer to, so it is meant for idempotent functors which
+// do not change it and whose latency has a long tail.
+type Hedge struct {
+	Delay    time.Duration
+	MaxExtra int
+}
+
+func NewHedge(delay time.Duration, maxExtra int) *Hedge {
+	return &Hedge{Delay: delay, MaxExtra: maxExtra}
+}
+
+type hedgeOutcome struct {
+	attempt int
+	result  *_Result
+}
+
+// run calls attempt until one succeeds or all the attempts started have failed, in which case the failure of the
+// first one is returned. It returns as soon as the outcome is known, the attempts still running are cancelled and left
+// to return in the background. A panic of an attempt is a failure with PanicHappened. The observers are told with
+// HedgeEvent if any extra attempt was started.
+func (h *Hedge) run(data *_Data, attempt ICallable, clock Clock, note string, functor string) *_Result {
+	base := GetContext(data)
+	if base == nil {
+		base = context.Background()
+	}
+	ctx, cancel := context.WithCancel(base)
+	defer cancel()
+
+	start := clock.Now()
+	outcomes := make(chan *hedgeOutcome, h.MaxExtra+1)
+	copies := make([]*_Data, 0, h.MaxExtra+1)
+	launch := func() {
+		copied := new(_Data)
+		*copied = *data
+		SetContext(copied, ctx)
+		outcome := &hedgeOutcome{attempt: len(copies)}
+		copies = append(copies, copied)
+		go func() {
+			defer func() {
+				if a := recover(); a != nil {
+					outcome.result = &_Result{Err: NewPanicHappened(string(debug.Stack()))}
+				}
+				outcomes <- outcome
+			}()
+			outcome.result = attempt(copied)
+		}()
+	}
+
+	launch()
+	pending, winner := 1, -1
+	var result, failure *_Result
+	timer := clock.NewTimer(h.Delay)
+	defer timer.Stop()
+	for winner < 0 && pending > 0 {
+		select {
+		case outcome := <-outcomes:
+			pending--
+			if outcome.result == nil || outcome.result == Return ||
+				(outcome.result.Err == nil && outcome.result.StatusCode == 0) {
+				winner, result = outcome.attempt, outcome.result
+			} else if failure == nil {
+				failure = outcome.result
+			}
+		case <-timer.C():
+			if len(copies) <= h.MaxExtra {
+				launch()
+				pending++
+				timer.Reset(h.Delay)
+			}
+		}
+	}
+
+	if len(copies) > 1 {
+		notify(&Event{Kind: HedgeEvent, Note: note, Functor: functor, Hedges: len(copies) - 1, Winner: winner,
+			Duration: clock.Now().Sub(start)})
+	}
+	if winner < 0 {
+		return failure
+	}
+	adopt(data, copies[winner])
+	return result
+}
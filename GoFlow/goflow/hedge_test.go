@@ -0,0 +1,61 @@
+package goflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHedgeTakesTheFirstAttemptWhichSucceeds(t *testing.T) {
+	var events []*Event
+	observer := func(event *Event) {
+		if event.Kind == HedgeEvent {
+			events = append(events, event)
+		}
+	}
+	defer AddObserver(observer)()
+
+	clock := newTestClock()
+	attempts, release := make(chan int, 3), make(chan struct{})
+	flow := NewFlow().SetClock(clock).Do(func(_data *_Data) *_Result {
+		attempt := <-attempts
+		if attempt == 0 {
+			<-release
+		}
+		_data.FunctionName += "attempt;"
+		return nil
+	}).SetHedge(time.Second, 2).SetNote("hedged")
+	attempts <- 0
+	attempts <- 1
+
+	done := make(chan *_Result, 1)
+	go func() {
+		done <- flow.Wait()
+	}()
+	clock.waitForTimers(t, 1)
+	clock.advance(time.Second)
+	result := <-done
+	close(release)
+	if result.Err != nil {
+		t.Errorf("expected the second attempt to succeed, got %v", result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "attempt;" {
+		t.Errorf("expected the data of the winning attempt, got %q", data)
+	}
+	if len(events) != 1 || events[0].Hedges != 1 || events[0].Winner != 1 || events[0].Note != "hedged" {
+		t.Errorf("expected the observer to be told about one extra attempt which won, got %d events", len(events))
+	}
+}
+
+func TestHedgeFailsOnceAllAttemptsFail(t *testing.T) {
+	clock := newTestClock()
+	flow := NewFlow().SetClock(clock).Do(func(_data *_Data) *_Result {
+		_data.FunctionName += "failed;"
+		return fail(_data)
+	}).SetHedge(time.Second, 0)
+	if result := flow.Wait(); result.Err != errTest {
+		t.Errorf("expected the failure of the attempt, got %v", result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "" {
+		t.Errorf("expected the data to be left as it was, got %q", data)
+	}
+}
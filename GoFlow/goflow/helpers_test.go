@@ -0,0 +1,134 @@
+package goflow
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test failure")
+
+func succeed(_data *_Data) *_Result {
+	return nil
+}
+
+func fail(_data *_Data) *_Result {
+	return &_Result{Err: errTest}
+}
+
+func always(_data *_Data) bool {
+	return true
+}
+
+func never(_data *_Data) bool {
+	return false
+}
+
+// mark appends the name to FunctionName of the data, so that the tests can tell which functors ran and in what order
+func mark(name string) ICallable {
+	return func(_data *_Data) *_Result {
+		_data.FunctionName += name + ";"
+		return nil
+	}
+}
+
+func assertContains(t *testing.T, text string, parts ...string) {
+	t.Helper()
+	for _, part := range parts {
+		if !strings.Contains(text, part) {
+			t.Errorf("%q is not found in:\n%s", part, text)
+		}
+	}
+}
+
+// testClock is a Clock which only moves by advance
+type testClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*testTimer
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *testClock) NewTimer(d time.Duration) Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timer := &testTimer{clock: c, c: make(chan time.Time, 1), deadline: c.now.Add(d), active: true}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// advance moves the clock and fires the timers whose deadline has come
+func (c *testClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	for _, timer := range c.timers {
+		if timer.active && !timer.deadline.After(c.now) {
+			timer.active = false
+			timer.c <- c.now
+		}
+	}
+}
+
+// pending returns the number of timers which have not fired or been stopped
+func (c *testClock) pending() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	count := 0
+	for _, timer := range c.timers {
+		if timer.active {
+			count++
+		}
+	}
+	return count
+}
+
+// waitForTimers waits until count timers are pending
+func (c *testClock) waitForTimers(t *testing.T, count int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.pending() < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d timers, got %d", count, c.pending())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type testTimer struct {
+	clock    *testClock
+	c        chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+func (t *testTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *testTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *testTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	active := t.active
+	t.active, t.deadline = true, t.clock.now.Add(d)
+	return active
+}
@@ -0,0 +1,104 @@
+package goflow
+
+import (
+	"sync"
+	"time"
+)
+
+type EventKind int64
+
+const (
+	// RateLimitWaitEvent is sent after a functor has waited for a token of a rate limiter
+	RateLimitWaitEvent EventKind = iota
+	// CircuitStateEvent is sent when a circuit breaker changes its state
+	CircuitStateEvent
+	// CacheHitEvent is sent when a node is skipped because its cache has the outcome, CacheMissEvent when it runs
+	CacheHitEvent
+	CacheMissEvent
+	// HedgeEvent is sent when a hedged functor has finished after extra attempts were started
+	HedgeEvent
+)
+
+func (e EventKind) String() string {
+	switch e {
+	case RateLimitWaitEvent:
+		return "RateLimitWait"
+	case CircuitStateEvent:
+		return "CircuitState"
+	case CacheHitEvent:
+		return "CacheHit"
+	case CacheMissEvent:
+		return "CacheMiss"
+	case HedgeEvent:
+		return "Hedge"
+	}
+	return "Unknown"
+}
+
+// Event is what the observers are told about. Note is the note of the node, and Functor is the registered name of the
+// functor if the event is about one. Circuit is the name of the circuit breaker and Key the key of the cache. Hedges is
+// the number of extra attempts of a hedged functor, and Winner the attempt which succeeded, 0 for the first one and -1
+// if none did.
+type Event struct {
+	Kind     EventKind
+	Note     string
+	Functor  string
+	Key      string
+	Circuit  string
+	State    CircuitState
+	Hedges   int
+	Winner   int
+	Duration time.Duration
+	Err      error
+}
+
+type IObserver = func(event *Event)
+
+type addedObserver struct {
+	id       int
+	observer IObserver
+}
+
+// observers.list is replaced rather than changed in place, so that notify can call the observers it read outside of
+// the lock
+var observers = struct {
+	sync.RWMutex
+	list []addedObserver
+	next int
+}{}
+
+// AddObserver registers an observer of the events of all the flows in the process, e.g. to export them as metrics,
+// and returns the function removing it. The observers are called synchronously, so they should return quickly. They
+// may add or remove observers, which applies from the next event.
+func AddObserver(observer IObserver) (remove func()) {
+	observers.Lock()
+	defer observers.Unlock()
+	observers.next++
+	id := observers.next
+	observers.list = append(observers.list, addedObserver{id: id, observer: observer})
+	return func() {
+		removeObserver(id)
+	}
+}
+
+func removeObserver(id int) {
+	observers.Lock()
+	defer observers.Unlock()
+	for index, added := range observers.list {
+		if added.id == id {
+			list := make([]addedObserver, 0, len(observers.list)-1)
+			observers.list = append(append(list, observers.list[:index]...), observers.list[index+1:]...)
+			return
+		}
+	}
+}
+
+// notify calls the observers outside of the lock, so that an observer can add or remove observers
+func notify(event *Event) {
+	observers.RLock()
+	list := observers.list
+	observers.RUnlock()
+	for _, added := range list {
+		added.observer(event)
+	}
+}
@@ -0,0 +1,60 @@
+package goflow
+
+import "testing"
+
+func TestObserversCanBeRemoved(t *testing.T) {
+	var first, second int
+	observe := func(count *int) IObserver {
+		return func(event *Event) {
+			*count++
+		}
+	}
+	removeFirst := AddObserver(observe(&first))
+	removeSecond := AddObserver(observe(&second))
+	notify(&Event{Kind: HedgeEvent})
+	removeFirst()
+	removeFirst()
+	notify(&Event{Kind: HedgeEvent})
+	removeSecond()
+	notify(&Event{Kind: HedgeEvent})
+	if first != 1 || second != 2 {
+		t.Errorf("expected the observers to stop once removed, got %d and %d", first, second)
+	}
+}
+
+func TestTheSameObserverCanBeAddedTwice(t *testing.T) {
+	var count int
+	observer := func(event *Event) {
+		count++
+	}
+	removeFirst := AddObserver(observer)
+	defer AddObserver(observer)()
+	notify(&Event{Kind: HedgeEvent})
+	removeFirst()
+	notify(&Event{Kind: HedgeEvent})
+	if count != 3 {
+		t.Errorf("expected the observer to be told twice then once, got %d", count)
+	}
+}
+
+func TestObserverCanAddObservers(t *testing.T) {
+	var added int
+	observeAdded := func(event *Event) {
+		added++
+	}
+	var removeOnce, removeAdded func()
+	removeOnce = AddObserver(func(event *Event) {
+		removeOnce()
+		removeAdded = AddObserver(observeAdded)
+	})
+
+	notify(&Event{Kind: HedgeEvent})
+	defer removeAdded()
+	if added != 0 {
+		t.Errorf("expected the added observer to start from the next event, got %d", added)
+	}
+	notify(&Event{Kind: HedgeEvent})
+	if added != 1 {
+		t.Errorf("expected the added observer to be told, got %d", added)
+	}
+}
@@ -0,0 +1,282 @@
+package goflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+type PlanDecision int64
+
+const (
+	// NotEvaluatedDecision is the decision of every step of Plan, which does not evaluate any condition
+	NotEvaluatedDecision PlanDecision = iota
+	WouldRunDecision
+	// NotTakenDecision means the condition of the step would be false
+	NotTakenDecision
+	// WouldSkipDecision means the step would not be reached, e.g. an Else after a taken If or the steps of a
+	// sub-path which is not taken
+	WouldSkipDecision
+	// WouldFailDecision means the step has no condition, which fails the flow at this step
+	WouldFailDecision
+)
+
+func (p PlanDecision) String() string {
+	switch p {
+	case NotEvaluatedDecision:
+		return "NotEvaluated"
+	case WouldRunDecision:
+		return "WouldRun"
+	case NotTakenDecision:
+		return "NotTaken"
+	case WouldSkipDecision:
+		return "WouldSkip"
+	case WouldFailDecision:
+		return "WouldFail"
+	}
+	return "Unknown"
+}
+
+type PlanStep struct {
+	// Depth is 0 for the nodes of the flow and grows by one for each sub-path
+	Depth int
+	Kind  string
+	Note  string
+	// Condition is the name of the condition of a conditional step, or of the signal of a WaitForSignal
+	Condition string
+	Functors  []string
+	// Times is the count of a For, or the number of functors which must succeed for a Quorum
+	Times    int
+	Decision PlanDecision
+	Node     IBasicFlowNode
+}
+
+// Plan is the list of nodes of a flow in execution order, with the nodes of every sub-path right after the node
+// owning it.
+type Plan struct {
+	Steps []*PlanStep
+}
+
+func newPlanStep(node IBasicFlowNode, depth int) *PlanStep {
+	step := &PlanStep{Depth: depth, Kind: nodeKind(node), Note: node.GetNote(), Node: node}
+	switch n := node.(type) {
+	case *PrepareNode:
+		step.Functors = prepareNames(n, n.Functors)
+	case *NormalNode:
+		step.Functors = functorNames(n, n.Functors)
+	case *ForNode:
+		step.Times, step.Functors = n.Times, functorNames(n, n.Functors)
+	case *ParallelNode:
+		step.Functors = functorNames(n, n.Functors)
+	case *RaceNode:
+		step.Functors = functorNames(n, n.Functors)
+	case *QuorumNode:
+		step.Times, step.Functors = n.Needed, functorNames(n, n.Functors)
+	case *SignalNode:
+		step.Condition = n.Name
+	case *IfNode:
+		step.Condition, step.Functors = conditionName(n, n.Condition), functorNames(n, n.Functors)
+	case *ElseIfNode:
+		step.Condition, step.Functors = conditionName(n, n.Condition), functorNames(n, n.Functors)
+	case *ElseNode:
+		step.Functors = functorNames(n, n.Functors)
+	case *FinallyNode:
+		step.Functors = functorNames(n, n.Functors)
+	case *CatchNode:
+		step.Functors = []string{functionName(n.Handler)}
+	case *IfSubPathNode:
+		step.Condition = conditionName(n, n.Condition)
+	case *ElseIfSubPathNode:
+		step.Condition = conditionName(n, n.Condition)
+	}
+	return step
+}
+
+func nodeKind(node IBasicFlowNode) string {
+	switch node.(type) {
+	case *PrepareNode:
+		return "Prepare"
+	case *NormalNode:
+		return "Do"
+	case *ForNode:
+		return "For"
+	case *ParallelNode:
+		return "Parallel"
+	case *IfNode:
+		return "If"
+	case *ElseIfNode:
+		return "ElseIf"
+	case *ElseNode:
+		return "Else"
+	case *IfSubPathNode:
+		return "IfSubPath"
+	case *ElseIfSubPathNode:
+		return "ElseIfSubPath"
+	case *ElseSubPathNode:
+		return "ElseSubPath"
+	case *FinallyNode:
+		return "Finally"
+	case *FinallySubPathNode:
+		return "FinallySubPath"
+	case *TrySubPathNode:
+		return "TrySubPath"
+	case *CatchNode:
+		return "Catch"
+	case *RaceNode:
+		return "Race"
+	case *RaceSubPathNode:
+		return "RaceSubPath"
+	case *QuorumNode:
+		return "Quorum"
+	case *SignalNode:
+		return "WaitForSignal"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*")
+}
+
+// conditionOf returns the condition of a conditional node and whether the node has one at all
+func conditionOf(node IBasicFlowNode) (IBoolFunc, bool) {
+	switch n := node.(type) {
+	case *IfNode:
+		return n.Condition, true
+	case *ElseIfNode:
+		return n.Condition, true
+	case *IfSubPathNode:
+		return n.Condition, true
+	case *ElseIfSubPathNode:
+		return n.Condition, true
+	}
+	return nil, false
+}
+
+func newPlan(engine IFlowEngine) *Plan {
+	plan := &Plan{}
+	walkNodes(engine, 0, func(node IBasicFlowNode, depth int) {
+		plan.Steps = append(plan.Steps, newPlanStep(node, depth))
+	})
+	return plan
+}
+
+// newDryRun evaluates the conditions of the flow against the data, without calling any functor or prepare
+// function, and decides which steps would run.
+func newDryRun(engine IFlowEngine, data *_Data) *Plan {
+	plan := &Plan{}
+	dryRunSteps(plan, engine, 0, data, true)
+	return plan
+}
+
+// dryRunSteps returns true if the steps would make the flow fail. The only failure known without calling the functors
+// is a missing condition, and a Catch handling it is expected to recover the flow.
+func dryRunSteps(plan *Plan, engine IFlowEngine, depth int, data *_Data, reached bool) bool {
+	if engine == nil {
+		return false
+	}
+	chainTaken, failed := false, false
+	var failedAt IBasicFlowNode
+	previous := NotEvaluatedDecision
+	for _, node := range engine.getNodes() {
+		step := newPlanStep(node, depth)
+		plan.Steps = append(plan.Steps, step)
+
+		condition, conditional := conditionOf(node)
+		switch {
+		case !reached:
+			step.Decision = WouldSkipDecision
+		case node.GetNodeType() == FinallyNodeType || node.GetNodeType() == FinallySubPathNodeType:
+			// The finally nodes run after a failure as well
+			step.Decision = WouldRunDecision
+			chainTaken = false
+		case node.GetNodeType() == CatchNodeType:
+			catch := node.(*CatchNode)
+			if catch.Previous != nil && previous != NotTakenDecision {
+				step.Decision = WouldSkipDecision
+			} else if !failed || (catch.Try != nil && IBasicFlowNode(catch.Try) != failedAt) ||
+				(catch.Match != nil && !catch.Match(&_Result{Err: NewConditionNotFoundError()})) {
+				step.Decision = NotTakenDecision
+			} else {
+				step.Decision = WouldRunDecision
+				failed = false
+			}
+			chainTaken = false
+		case failed:
+			step.Decision = WouldSkipDecision
+		case isBranchTail(node) && chainTaken:
+			step.Decision = WouldSkipDecision
+		case conditional && condition == nil:
+			step.Decision = WouldFailDecision
+			failed, failedAt = true, node
+		case conditional:
+			if condition(data) {
+				step.Decision = WouldRunDecision
+				chainTaken = true
+			} else {
+				step.Decision = NotTakenDecision
+				chainTaken = false
+			}
+		default:
+			step.Decision = WouldRunDecision
+			chainTaken = false
+		}
+
+		// A race fails only if all of its sub-paths fail
+		subPaths, subPathFailures := subPathsOf(node), 0
+		for _, subPath := range subPaths {
+			if dryRunSteps(plan, subPath, depth+1, data, step.Decision == WouldRunDecision) {
+				subPathFailures++
+				if node.GetNodeType() != RaceSubPathNodeType {
+					failed, failedAt = true, node
+				}
+			}
+		}
+		if node.GetNodeType() == RaceSubPathNodeType && len(subPaths) != 0 && subPathFailures == len(subPaths) {
+			failed, failedAt = true, node
+		}
+		previous = step.Decision
+	}
+	return failed
+}
+
+func (f *FlowEngine) Plan() *Plan {
+	return newPlan(f)
+}
+
+// DryRun tells which nodes would run with the data. Only the conditions are evaluated, so they see the data as it
+// is given, without the changes the functors would make.
+func (f *FlowEngine) DryRun(data *_Data) *Plan {
+	return newDryRun(f, data)
+}
+
+func (e *ElseFlowEngine) Plan() *Plan {
+	return newPlan(e)
+}
+
+func (e *ElseFlowEngine) DryRun(data *_Data) *Plan {
+	return newDryRun(e, data)
+}
+
+func (p *Plan) String() string {
+	builder := &strings.Builder{}
+	for _, step := range p.Steps {
+		builder.WriteString(strings.Repeat("    ", step.Depth))
+		builder.WriteString(step.Kind)
+		if step.Condition != "" {
+			builder.WriteString(" " + step.Condition)
+		}
+		if step.Kind == "For" {
+			builder.WriteString(fmt.Sprintf(" %d times", step.Times))
+		}
+		if step.Kind == "Quorum" {
+			builder.WriteString(fmt.Sprintf(" %d of %d", step.Times, len(step.Functors)))
+		}
+		if len(step.Functors) != 0 {
+			builder.WriteString(": " + strings.Join(step.Functors, ", "))
+		}
+		if step.Note != "" {
+			builder.WriteString(" (" + step.Note + ")")
+		}
+		if step.Decision != NotEvaluatedDecision {
+			builder.WriteString(" => " + step.Decision.String())
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,65 @@
+package goflow
+
+import "testing"
+
+func TestPlanListsNodesInOrder(t *testing.T) {
+	flow := NewFlow().Do(succeed).SetNote("first").For(3, succeed).
+		IfSubPath(always, NewFlow().Do(fail)).ElseSubPath(NewFlow().Do(succeed)).
+		Quorum(2, succeed, succeed, fail).WaitForSignal("approved", 0)
+	expected := `Do: goflow.succeed (first)
+For 3 times: goflow.succeed
+IfSubPath goflow.always
+    Do: goflow.fail
+ElseSubPath
+    Do: goflow.succeed
+Quorum 2 of 3: goflow.succeed, goflow.succeed, goflow.fail
+WaitForSignal approved
+`
+	plan := flow.Plan()
+	if plan.String() != expected {
+		t.Errorf("unexpected plan:\n%s", plan)
+	}
+	if plan.Steps[3].Depth != 1 || plan.Steps[3].Node != flow.getNodes()[2].(*IfSubPathNode).SubPath.getNodes()[0] {
+		t.Errorf("expected the node of the sub-path, got %+v", plan.Steps[3])
+	}
+}
+
+func TestDryRunEvaluatesConditionsOnly(t *testing.T) {
+	calls := 0
+	counted := func(_data *_Data) *_Result {
+		calls++
+		return nil
+	}
+	flow := NewFlow().Do(counted).If(never, counted).ElseIf(always, counted).Else(counted).
+		IfSubPath(never, NewFlow().Do(counted)).Finally(counted)
+	plan := flow.DryRun(flow.getData())
+
+	expected := []PlanDecision{WouldRunDecision, NotTakenDecision, WouldRunDecision, WouldSkipDecision,
+		NotTakenDecision, WouldSkipDecision, WouldRunDecision}
+	if len(plan.Steps) != len(expected) {
+		t.Fatalf("expected %d steps, got:\n%s", len(expected), plan)
+	}
+	for index, decision := range expected {
+		if actual := plan.Steps[index].Decision; actual != decision {
+			t.Errorf("step %d: expected %s, got %s", index, decision, actual)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("expected no functor to be called, got %d calls", calls)
+	}
+}
+
+func TestDryRunFindsMissingCondition(t *testing.T) {
+	flow := NewFlow().If(nil, succeed).Do(succeed).Catch(nil, func(_data *_Data, _result *_Result) *_Result {
+		return nil
+	}).Do(succeed)
+	plan := flow.DryRun(flow.getData())
+
+	expected := []PlanDecision{WouldFailDecision, WouldSkipDecision, WouldRunDecision, WouldRunDecision}
+	for index, decision := range expected {
+		if actual := plan.Steps[index].Decision; actual != decision {
+			t.Errorf("step %d: expected %s, got %s", index, decision, actual)
+		}
+	}
+	assertContains(t, plan.String(), "If nil: goflow.succeed => WouldFail\n", "Catch: ")
+}
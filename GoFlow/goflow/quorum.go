@@ -0,0 +1,112 @@
+package goflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QuorumOutcome is the outcome of one functor of a Quorum node. AfterDecision is true if the functor had not returned
+// when the quorum was reached or known to be out of reach, its Result is then nil since the node did not wait for it.
+type QuorumOutcome struct {
+	Functor       string
+	Result        *_Result
+	Succeeded     bool
+	AfterDecision bool
+}
+
+// QuorumResult is the outcome of a Quorum node, Outcomes are in the order of the functors
+type QuorumResult struct {
+	Note      string
+	Needed    int
+	Succeeded int
+	Failed    int
+	Reached   bool
+	Outcomes  []*QuorumOutcome
+}
+
+// QuorumError is the failure of a Quorum node whose quorum could not be reached
+type QuorumError struct {
+	Result *QuorumResult
+}
+
+func NewQuorumError(result *QuorumResult) *QuorumError {
+	return &QuorumError{Result: result}
+}
+
+func (q *QuorumError) Error() string {
+	messages := make([]string, 0, q.Result.Failed)
+	for _, outcome := range q.Result.Outcomes {
+		if outcome.Succeeded || outcome.Result == nil {
+			continue
+		}
+		if outcome.Result.Err != nil {
+			messages = append(messages, outcome.Result.Err.Error())
+		} else {
+			messages = append(messages, fmt.Sprintf("status code %d: %s", outcome.Result.StatusCode, outcome.Result.StatusMsg))
+		}
+	}
+	return fmt.Sprintf("quorum of %d not reached, %d of %d succeeded: %s", q.Result.Needed, q.Result.Succeeded,
+		len(q.Result.Outcomes), strings.Join(messages, "; "))
+}
+
+// quorum runs the functors concurrently, each on a copy of the data, and returns as soon as needed of them have
+// succeeded or so many have failed that it is not possible anymore. The remaining functors are left to return in the
+// background, with their context cancelled if CancelRemaining is set. The copy of the functor whose success reached the quorum
+// is put back into the data.
+func quorum(node *QuorumNode) *QuorumResult {
+	result := &QuorumResult{Note: node.Note, Needed: node.Needed, Outcomes: make([]*QuorumOutcome, len(node.Functors))}
+	branches := make([]func(data *_Data) *_Result, 0, len(node.Functors))
+	for index, functor := range node.Functors {
+		index, f := index, functor
+		result.Outcomes[index] = &QuorumOutcome{Functor: displayName(node.names.functor(index), f), AfterDecision: true}
+		branches = append(branches, func(data *_Data) *_Result {
+			return node.callOn(data, index, f)
+		})
+	}
+	pending := keepPending
+	if node.CancelRemaining {
+		pending = cancelPending
+	}
+	copies := newCopies(len(branches))
+	deciding := -1
+	runBranches(node.Data, copies, branches, pending, func(index int, branch *_Result) bool {
+		outcome := result.Outcomes[index]
+		outcome.Result, outcome.AfterDecision = branch, false
+		outcome.Succeeded = branch == nil || branch == Return || (branch.Err == nil && branch.StatusCode == 0)
+		if outcome.Succeeded {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+		if result.Succeeded >= result.Needed {
+			deciding = index
+			return true
+		}
+		return result.Failed > len(result.Outcomes)-result.Needed
+	})
+	result.Reached = result.Succeeded >= result.Needed
+	if deciding >= 0 {
+		adopt(node.Data, copies[deciding])
+	}
+	return result
+}
+
+func quorumResults(engine IFlowEngine) []*QuorumResult {
+	var results []*QuorumResult
+	walkNodes(engine, 0, func(node IBasicFlowNode, depth int) {
+		if n, ok := node.(*QuorumNode); ok && n.Result != nil && n.State != SkippedNodeState && n.State != NotRunNodeState {
+			results = append(results, n.Result)
+		}
+	})
+	return results
+}
+
+// QuorumResults returns the outcome of every Quorum node which ran in the last Wait, including the ones of the
+// sub-flows, in the order of the nodes
+func (f *FlowEngine) QuorumResults() []*QuorumResult {
+	return quorumResults(f)
+}
+
+func (e *ElseFlowEngine) QuorumResults() []*QuorumResult {
+	return quorumResults(e)
+}
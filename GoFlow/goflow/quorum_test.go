@@ -0,0 +1,65 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestQuorumReturnsOnceReached(t *testing.T) {
+	release := make(chan struct{})
+	flow := NewFlow().Quorum(2, mark("first"), func(_data *_Data) *_Result {
+		<-release
+		return nil
+	}, mark("second"))
+	result := flow.Wait()
+	close(release)
+	if result.Err != nil {
+		t.Fatalf("unexpected failure %v", result.Err)
+	}
+	results := flow.QuorumResults()
+	if len(results) != 1 || !results[0].Reached || results[0].Succeeded != 2 {
+		t.Fatalf("expected the quorum to be reached by two functors, got %+v", results)
+	}
+	if outcome := results[0].Outcomes[1]; !outcome.AfterDecision || outcome.Result != nil || outcome.Succeeded {
+		t.Errorf("expected the blocked functor not to be waited for, got %+v", outcome)
+	}
+	if data := flow.getData().FunctionName; data != "first;" && data != "second;" {
+		t.Errorf("expected the data of the functor which reached the quorum, got %q", data)
+	}
+}
+
+func TestQuorumFailsOnceOutOfReach(t *testing.T) {
+	cancelled := make(chan error, 1)
+	flow := NewFlow().Quorum(2, fail, fail, func(_data *_Data) *_Result {
+		<-GetContext(_data).Done()
+		cancelled <- GetContext(_data).Err()
+		return nil
+	}).SetQuorumCancel(true)
+	result := flow.Wait()
+	quorumErr, ok := result.Err.(*QuorumError)
+	if !ok || quorumErr.Result.Reached || quorumErr.Result.Failed != 2 {
+		t.Fatalf("expected QuorumError with two failures, got %v", result.Err)
+	}
+	if err := <-cancelled; err != context.Canceled {
+		t.Errorf("expected the remaining functor to be cancelled, got %v", err)
+	}
+	if flow.getData().FunctionName != "" {
+		t.Errorf("expected the data to be left as it was, got %q", flow.getData().FunctionName)
+	}
+}
+
+func TestQuorumKeepsTheContextOfTheRemainingFunctors(t *testing.T) {
+	release, remaining := make(chan struct{}), make(chan error, 1)
+	flow := NewFlow().Quorum(1, succeed, func(_data *_Data) *_Result {
+		<-release
+		remaining <- GetContext(_data).Err()
+		return nil
+	})
+	if result := flow.Wait(); result.Err != nil {
+		t.Fatalf("unexpected failure %v", result.Err)
+	}
+	close(release)
+	if err := <-remaining; err != nil {
+		t.Errorf("expected the remaining functor to keep its context, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package goflow
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// RaceError is the failure of a race whose branches all failed, Failures has the result of every branch in the order
+// they were given
+type RaceError struct {
+	Failures []*_Result
+}
+
+func NewRaceError(failures []*_Result) *RaceError {
+	return &RaceError{Failures: failures}
+}
+
+func (r *RaceError) Error() string {
+	messages := make([]string, 0, len(r.Failures))
+	for _, failure := range r.Failures {
+		if failure.Err != nil {
+			messages = append(messages, failure.Err.Error())
+		} else {
+			messages = append(messages, fmt.Sprintf("status code %d: %s", failure.StatusCode, failure.StatusMsg))
+		}
+	}
+	return fmt.Sprintf("all %d branches failed: %s", len(r.Failures), strings.Join(messages, "; "))
+}
+
+type branchOutcome struct {
+	index  int
+	result *_Result
+}
+
+// newCopies returns the data for count branches, see runBranches
+func newCopies(count int) []*_Data {
+	copies := make([]*_Data, count)
+	for index := range copies {
+		copies[index] = new(_Data)
+	}
+	return copies
+}
+
+// pending tells what runBranches does with the branches still running once the outcome of the node is known
+type pending int
+
+const (
+	// cancelPending cancels their context and leaves them to return in the background
+	cancelPending pending = iota
+	// waitPending cancels their context and waits for them to return
+	waitPending
+	// keepPending leaves them to return in the background with their context, which is cancelled once they all have
+	keepPending
+)
+
+// runBranches runs every branch in its own goroutine on copies[index], which is set to a copy of the data with a
+// context derived from the context of the data. The branches thus do not change the context of the data nor write the
+// same fields of it, while what the pointers, maps and slices of the data refer to is still shared. Every outcome is
+// passed to decide, which returns true once the outcome of the node is known, and the branches still running are then
+// handled as told by pending. The ones left in the background run on copies nobody reads anymore. A panic of a branch
+// becomes a failure with PanicHappened.
+func runBranches(data *_Data, copies []*_Data, branches []func(data *_Data) *_Result, pending pending,
+	decide func(index int, result *_Result) bool) {
+	base := GetContext(data)
+	if base == nil {
+		base = context.Background()
+	}
+	ctx, cancel := context.WithCancel(base)
+
+	outcomes := make(chan *branchOutcome, len(branches))
+	for index, branch := range branches {
+		*copies[index] = *data
+		SetContext(copies[index], ctx)
+		go func(index int, branch func(data *_Data) *_Result) {
+			outcome := &branchOutcome{index: index}
+			defer func() {
+				if a := recover(); a != nil {
+					outcome.result = &_Result{Err: NewPanicHappened(string(debug.Stack()))}
+				}
+				outcomes <- outcome
+			}()
+			outcome.result = branch(copies[index])
+		}(index, branch)
+	}
+	remaining := len(branches)
+	for remaining > 0 {
+		outcome := <-outcomes
+		remaining--
+		if decide(outcome.index, outcome.result) {
+			break
+		}
+	}
+
+	switch {
+	case remaining == 0 || pending == cancelPending:
+		cancel()
+	case pending == waitPending:
+		cancel()
+		for ; remaining > 0; remaining-- {
+			<-outcomes
+		}
+	default:
+		go func() {
+			for ; remaining > 0; remaining-- {
+				<-outcomes
+			}
+			cancel()
+		}()
+	}
+}
+
+// adopt puts the copy of the data a branch ran on back into the data, keeping the context of the data
+func adopt(data *_Data, copied *_Data) {
+	ctx := GetContext(data)
+	*data = *copied
+	SetContext(data, ctx)
+}
+
+// race returns the result of the first branch which succeeds and puts the copy of the data it ran on back into the
+// data, or returns RaceError once all of them have failed, leaving the data as it was. The branches still running
+// then are handled as told by pending.
+func race(data *_Data, copies []*_Data, branches []func(data *_Data) *_Result, pending pending) *_Result {
+	failures := make([]*_Result, len(branches))
+	winner := -1
+	var result *_Result
+	runBranches(data, copies, branches, pending, func(index int, branch *_Result) bool {
+		if branch != nil && branch != Return && (branch.Err != nil || branch.StatusCode != 0) {
+			failures[index] = branch
+			return false
+		}
+		winner, result = index, branch
+		return true
+	})
+	if winner >= 0 {
+		adopt(data, copies[winner])
+		return result
+	}
+	if len(branches) == 0 {
+		return nil
+	}
+	return &_Result{Err: NewRaceError(failures)}
+}
+
+// attachBranch attaches a sub-path of a RaceSubPath node to a flow of its own, so that it has its own data and its own
+// result and the failure of a branch does not make the nodes of the other branches skip. It is called once when the
+// node is built, and returns the data the branch runs on.
+func attachBranch(subPath IFlowEngine) *_Data {
+	owner := NewFlowEngine()
+	subPath.Attach(owner)
+	return owner.data
+}
+
+// subPathBranch runs a sub-path attached by attachBranch on the copy of the data given by runBranches, which is the
+// data it is attached to
+func subPathBranch(subPath IFlowEngine) func(data *_Data) *_Result {
+	return func(data *_Data) *_Result {
+		*subPath.getResult() = new(_Result)
+		subPath.setContext(GetContext(data))
+		return subPath.Wait()
+	}
+}
@@ -0,0 +1,82 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+)
+
+type contextKey struct{}
+
+func TestRaceReturnsOnceABranchWins(t *testing.T) {
+	parent := context.WithValue(context.Background(), contextKey{}, "parent")
+	release, loserCtx := make(chan struct{}), make(chan context.Context, 1)
+	flow := NewFlow().Race(func(_data *_Data) *_Result {
+		loserCtx <- GetContext(_data)
+		<-release
+		_data.FunctionName += "loser;"
+		return nil
+	}, func(_data *_Data) *_Result {
+		_data.FunctionName += "winner;"
+		return &_Result{StatusMsg: "won"}
+	})
+	SetContext(flow.getData(), parent)
+
+	result := flow.Wait()
+	ctx := <-loserCtx
+	close(release)
+	if result.Err != nil || result.StatusMsg != "won" {
+		t.Errorf("expected the result of the winner, got %+v", result)
+	}
+	if data := flow.getData(); data.FunctionName != "winner;" || GetContext(data) != parent {
+		t.Errorf("expected the data of the winner with the context of the flow, got %q", data.FunctionName)
+	}
+	if ctx.Value(contextKey{}) != "parent" || ctx.Err() != context.Canceled {
+		t.Errorf("expected the loser to run with a cancelled context derived from the flow, got %v", ctx.Err())
+	}
+}
+
+func TestRaceFailsOnceAllBranchesFail(t *testing.T) {
+	flow := NewFlow().Race(func(_data *_Data) *_Result {
+		_data.FunctionName += "first;"
+		return fail(_data)
+	}, func(_data *_Data) *_Result {
+		panic("broken")
+	})
+	result := flow.Wait()
+	raceErr, ok := result.Err.(*RaceError)
+	if !ok || len(raceErr.Failures) != 2 {
+		t.Fatalf("expected RaceError with two failures, got %v", result.Err)
+	}
+	if _, ok := raceErr.Failures[1].Err.(*PanicHappened); !ok {
+		t.Errorf("expected the panic to be a failure, got %v", raceErr.Failures[1].Err)
+	}
+	if flow.getData().FunctionName != "" {
+		t.Errorf("expected the data to be left as it was, got %q", flow.getData().FunctionName)
+	}
+}
+
+func TestRaceSubPathRunsAgain(t *testing.T) {
+	failed, lost := 0, make(chan struct{})
+	losing := NewFlow().Do(func(_data *_Data) *_Result {
+		failed++
+		lost <- struct{}{}
+		return fail(_data)
+	})
+	winning := NewFlow().Do(func(_data *_Data) *_Result {
+		<-lost
+		return mark("first")(_data)
+	}).Do(mark("second"))
+	flow := NewFlow().RaceSubPath(losing, winning).Do(mark("after"))
+	for run := 1; run <= 2; run++ {
+		flow.getData().FunctionName = ""
+		if result := flow.Wait(); result.Err != nil {
+			t.Fatalf("unexpected failure %v in run %d", result.Err, run)
+		}
+		if data := flow.getData().FunctionName; data != "first;second;after;" {
+			t.Errorf("expected the data of the winning sub-path in run %d, got %q", run, data)
+		}
+		if failed != run {
+			t.Errorf("expected the losing sub-path to run again in run %d, got %d runs", run, failed)
+		}
+	}
+}
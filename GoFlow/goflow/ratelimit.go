@@ -0,0 +1,96 @@
+package goflow
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IRateLimiter blocks until the caller may go on or the context is done. The Limiter of golang.org/x/time/rate
+// implements it as well.
+type IRateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// TokenBucket lets Rate calls per second through on average, and up to Burst calls at once after a quiet period. A
+// Rate of 0 or less does not limit the calls at all.
+// It is meant to be shared by all the flows calling the same dependency, so it follows its own Clock rather than the
+// one of a flow.
+type TokenBucket struct {
+	Rate  float64
+	Burst int
+	Clock Clock
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{Rate: rate, Burst: burst, tokens: float64(burst)}
+}
+
+func (t *TokenBucket) SetClock(clock Clock) *TokenBucket {
+	t.Clock = clock
+	return t
+}
+
+// reserve takes a token, which may be one still to come, and returns how long to wait for it
+func (t *TokenBucket) reserve() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := clockOr(t.Clock).Now()
+	if t.last.IsZero() {
+		t.last = now
+	}
+	t.tokens += now.Sub(t.last).Seconds() * t.Rate
+	if t.tokens > float64(t.Burst) {
+		t.tokens = float64(t.Burst)
+	}
+	t.last = now
+	t.tokens--
+	if t.tokens >= 0 || t.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens / t.Rate * float64(time.Second))
+}
+
+// cancel gives back a token reserved by a caller which stopped waiting
+func (t *TokenBucket) cancel() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.tokens++
+}
+
+func (t *TokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	delay := t.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	timer := clockOr(t.Clock).NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		t.cancel()
+		return ctx.Err()
+	}
+}
+
+// waitForToken waits for the limiter and tells the observers how long it took according to the clock of the flow
+func waitForToken(ctx context.Context, limiter IRateLimiter, clock Clock, note string, functor string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	start := clock.Now()
+	err := limiter.Wait(ctx)
+	notify(&Event{Kind: RateLimitWaitEvent, Note: note, Functor: functor, Duration: clock.Now().Sub(start), Err: err})
+	return err
+}
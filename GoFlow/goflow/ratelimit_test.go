@@ -0,0 +1,91 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketLetsTheBurstThrough(t *testing.T) {
+	clock := newTestClock()
+	bucket := NewTokenBucket(2, 2).SetClock(clock)
+	for index := 0; index < 2; index++ {
+		if delay := bucket.reserve(); delay != 0 {
+			t.Fatalf("expected call %d of the burst to go through, got a delay of %s", index, delay)
+		}
+	}
+	if delay := bucket.reserve(); delay != 500*time.Millisecond {
+		t.Errorf("expected to wait for the next token, got %s", delay)
+	}
+	clock.advance(time.Second)
+	if delay := bucket.reserve(); delay != 0 {
+		t.Errorf("expected the tokens to come back with the time, got a delay of %s", delay)
+	}
+}
+
+func TestTokenBucketWithoutRateIsUnlimited(t *testing.T) {
+	bucket := NewTokenBucket(0, 1).SetClock(newTestClock())
+	for index := 0; index < 10; index++ {
+		if delay := bucket.reserve(); delay != 0 {
+			t.Fatalf("expected call %d to go through, got a delay of %s", index, delay)
+		}
+	}
+}
+
+func TestTokenBucketWaits(t *testing.T) {
+	clock := newTestClock()
+	bucket := NewTokenBucket(1, 1).SetClock(clock)
+	if err := bucket.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- bucket.Wait(context.Background())
+	}()
+	clock.waitForTimers(t, 1)
+	clock.advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("expected the token to come, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		done <- bucket.Wait(ctx)
+	}()
+	clock.waitForTimers(t, 1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+	if bucket.tokens != 0 {
+		t.Errorf("expected the token of the cancelled wait to be given back, got %v tokens", bucket.tokens)
+	}
+}
+
+func TestRateLimitOfANode(t *testing.T) {
+	var events []*Event
+	observer := func(event *Event) {
+		events = append(events, event)
+	}
+	defer AddObserver(observer)()
+
+	limiter := &countingLimiter{}
+	result := NewFlow().Do(succeed, succeed).SetRateLimit(limiter).SetNote("limited").Wait()
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if limiter.waits != 2 {
+		t.Errorf("expected every functor to wait, got %d", limiter.waits)
+	}
+	if len(events) != 2 || events[0].Kind != RateLimitWaitEvent || events[0].Note != "limited" {
+		t.Errorf("expected the observer to be told about the waits, got %d events", len(events))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	flow := NewFlow().Do(mark("limited")).SetRateLimit(NewTokenBucket(1, 1))
+	SetContext(flow.getData(), ctx)
+	if result := flow.Wait(); result.Err != context.Canceled || flow.getData().FunctionName != "" {
+		t.Errorf("expected the functor not to run once the context is done, got %v", result.Err)
+	}
+}
@@ -0,0 +1,409 @@
+package goflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+)
+
+// RecordedResult is a _Result returned by a functor. The error can not be restored as it was, so only its message is
+// kept and it is replayed as a RecordedError.
+type RecordedResult struct {
+	Nil      bool            `json:"nil,omitempty"`
+	Returned bool            `json:"returned,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// RecordedCall is a call of a functor with its result and the data right after it. Node is the position of the node
+// in the flow, such as 2.0.1 for the second node of the first sub-path of the third node, or the name of a DAG step.
+// Input is the _PrepareInput of the functors of Prepare.
+type RecordedCall struct {
+	Node    string          `json:"node"`
+	Note    string          `json:"note,omitempty"`
+	Functor string          `json:"functor"`
+	Input   json.RawMessage `json:"input,omitempty"`
+	Result  *RecordedResult `json:"result"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type RecordedCondition struct {
+	Node      string `json:"node"`
+	Note      string `json:"note,omitempty"`
+	Condition string `json:"condition"`
+	Outcome   bool   `json:"outcome"`
+}
+
+// Recording is a run of a flow: the data it started with, the calls of the functors and the outcomes of the conditions
+// in the order they happened, and the result of the flow
+type Recording struct {
+	Data       json.RawMessage      `json:"data"`
+	Calls      []*RecordedCall      `json:"calls"`
+	Conditions []*RecordedCondition `json:"conditions"`
+	Result     *RecordedResult      `json:"result,omitempty"`
+}
+
+// ReadRecording reads a recording written by Recorder.WriteFile
+func ReadRecording(path string) (*Recording, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	recording := &Recording{}
+	if err := json.Unmarshal(content, recording); err != nil {
+		return nil, err
+	}
+	return recording, nil
+}
+
+type RecordedError struct {
+	Msg string
+}
+
+func NewRecordedError(msg string) *RecordedError {
+	return &RecordedError{Msg: msg}
+}
+
+func (r *RecordedError) Error() string {
+	return r.Msg
+}
+
+type ReplayMismatchError struct {
+	Kind     string
+	Node     string
+	Name     string
+	Recorded int
+	Replayed int
+}
+
+func NewReplayMismatchError(kind string, node string, name string, recorded int, replayed int) *ReplayMismatchError {
+	return &ReplayMismatchError{Kind: kind, Node: node, Name: name, Recorded: recorded, Replayed: replayed}
+}
+
+func (r *ReplayMismatchError) Error() string {
+	return fmt.Sprintf("%s %s of node %s is called %d times while the recording has %d", r.Kind, r.Name, r.Node,
+		r.Replayed, r.Recorded)
+}
+
+func recordResult(result *_Result) (*RecordedResult, error) {
+	if result == nil {
+		return &RecordedResult{Nil: true}, nil
+	}
+	if result == Return {
+		return &RecordedResult{Returned: true}, nil
+	}
+	copied := *result
+	copied.Err = nil
+	encoded, err := json.Marshal(&copied)
+	if err != nil {
+		return nil, err
+	}
+	recorded := &RecordedResult{Result: encoded}
+	if result.Err != nil {
+		recorded.Error = result.Err.Error()
+	}
+	return recorded, nil
+}
+
+func (r *RecordedResult) restore() (*_Result, error) {
+	if r == nil || r.Nil {
+		return nil, nil
+	}
+	if r.Returned {
+		return Return, nil
+	}
+	result := &_Result{}
+	if len(r.Result) != 0 {
+		if err := json.Unmarshal(r.Result, result); err != nil {
+			return nil, err
+		}
+	}
+	if r.Error != "" {
+		result.Err = NewRecordedError(r.Error)
+	}
+	return result, nil
+}
+
+// nodePosition is the position of the node at index in a flow whose nodes are at position, see RecordedCall
+func nodePosition(position string, index int) string {
+	return position + strconv.Itoa(index)
+}
+
+// subPathPosition is the position of the nodes of the sub-path at subIndex of the node at index
+func subPathPosition(position string, index int, subIndex int) string {
+	return nodePosition(position, index) + "." + strconv.Itoa(subIndex) + "."
+}
+
+// restoreData sets the data to the encoded one. It is decoded into a zero _Data, so that the fields the encoding leaves
+// out do not keep their current values, and the context of the data is kept.
+func restoreData(data *_Data, encoded json.RawMessage) error {
+	restored := &_Data{}
+	if err := json.Unmarshal(encoded, restored); err != nil {
+		return err
+	}
+	adopt(data, restored)
+	return nil
+}
+
+// tape is where the calls of the functors and the outcomes of the conditions of a run are recorded to, or replayed
+// from. The node is the position of the node calling them.
+type tape interface {
+	begin(data *_Data, result **_Result)
+	call(node string, note string, functor string, input interface{}, data *_Data, function func() *_Result) *_Result
+	condition(node string, note string, name string, data *_Data, condition IBoolFunc) bool
+	end(result *_Result)
+}
+
+// Recorder records the last run of the flows it is set to, including their sub-flows. The data is encoded after every
+// call, so the functors of a Parallel, Race or Quorum node and the concurrent steps of a DAG must not change it while
+// the others are still running.
+type Recorder struct {
+	mutex     sync.Mutex
+	recording *Recording
+	err       error
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{recording: &Recording{}}
+}
+
+// Recording returns what the last run recorded, and the first error encoding it
+func (r *Recorder) Recording() (*Recording, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.recording, r.err
+}
+
+func (r *Recorder) WriteFile(path string) error {
+	recording, err := r.Recording()
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(recording, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+func (r *Recorder) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Recorder) begin(data *_Data, result **_Result) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.recording, r.err = &Recording{}, nil
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		r.fail(err)
+	}
+	r.recording.Data = encoded
+}
+
+func (r *Recorder) call(node string, note string, functor string, input interface{}, data *_Data,
+	function func() *_Result) *_Result {
+	result := function()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	call := &RecordedCall{Node: node, Note: note, Functor: functor}
+	var err error
+	if input != nil {
+		if call.Input, err = json.Marshal(input); err != nil {
+			r.fail(err)
+		}
+	}
+	if call.Result, err = recordResult(result); err != nil {
+		r.fail(err)
+	}
+	if call.Data, err = json.Marshal(data); err != nil {
+		r.fail(err)
+	}
+	r.recording.Calls = append(r.recording.Calls, call)
+	return result
+}
+
+func (r *Recorder) condition(node string, note string, name string, data *_Data, condition IBoolFunc) bool {
+	outcome := condition(data)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.recording.Conditions = append(r.recording.Conditions,
+		&RecordedCondition{Node: node, Note: note, Condition: name, Outcome: outcome})
+	return outcome
+}
+
+func (r *Recorder) end(result *_Result) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	recorded, err := recordResult(result)
+	if err != nil {
+		r.fail(err)
+	}
+	r.recording.Result = recorded
+}
+
+// Replayer runs a flow again from a recording of the same flow definition. The functors and conditions are not called,
+// the data starts as recorded and every call of a functor returns its recorded result and sets the data as it was
+// after the call. The calls are matched by the position of their node, the name of the functor and the order of its
+// calls in the node, so a functor called more times than recorded fails with ReplayMismatchError. The other functions
+// of the flow, such as the handlers of Catch and OnFail, run as usual.
+type Replayer struct {
+	mutex      sync.Mutex
+	recording  *Recording
+	calls      map[replayKey][]*RecordedCall
+	conditions map[replayKey][]*RecordedCondition
+	err        error
+}
+
+// replayKey is the position of a node and the name of one of its functors or of its condition
+type replayKey struct {
+	node string
+	name string
+}
+
+func NewReplayer(recording *Recording) *Replayer {
+	return &Replayer{recording: recording}
+}
+
+// Err returns the first mismatch between the last replay and the recording, including the functors and conditions
+// called fewer times than recorded, or the first error decoding the recording
+func (r *Replayer) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+func (r *Replayer) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Replayer) begin(data *_Data, result **_Result) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.err = nil
+	r.calls = make(map[replayKey][]*RecordedCall)
+	for _, call := range r.recording.Calls {
+		key := replayKey{call.Node, call.Functor}
+		r.calls[key] = append(r.calls[key], call)
+	}
+	r.conditions = make(map[replayKey][]*RecordedCondition)
+	for _, condition := range r.recording.Conditions {
+		key := replayKey{condition.Node, condition.Condition}
+		r.conditions[key] = append(r.conditions[key], condition)
+	}
+	if err := restoreData(data, r.recording.Data); err != nil {
+		r.fail(err)
+		*result = &_Result{Err: err}
+	}
+}
+
+func (r *Replayer) call(node string, note string, functor string, input interface{}, data *_Data,
+	function func() *_Result) *_Result {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	key := replayKey{node, functor}
+	calls := r.calls[key]
+	if len(calls) == 0 {
+		recorded, _ := r.recorded(key)
+		err := NewReplayMismatchError("functor", node, functor, recorded, recorded+1)
+		r.fail(err)
+		return &_Result{Err: err}
+	}
+	call := calls[0]
+	r.calls[key] = calls[1:]
+	if err := restoreData(data, call.Data); err != nil {
+		r.fail(err)
+		return &_Result{Err: err}
+	}
+	result, err := call.Result.restore()
+	if err != nil {
+		r.fail(err)
+		return &_Result{Err: err}
+	}
+	return result
+}
+
+// recorded returns how many times the functor was called and the condition evaluated by the node in the recording
+func (r *Replayer) recorded(key replayKey) (int, int) {
+	calls, conditions := 0, 0
+	for _, call := range r.recording.Calls {
+		if call.Node == key.node && call.Functor == key.name {
+			calls++
+		}
+	}
+	for _, recorded := range r.recording.Conditions {
+		if recorded.Node == key.node && recorded.Condition == key.name {
+			conditions++
+		}
+	}
+	return calls, conditions
+}
+
+func (r *Replayer) condition(node string, note string, name string, data *_Data, condition IBoolFunc) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	key := replayKey{node, name}
+	conditions := r.conditions[key]
+	if len(conditions) == 0 {
+		_, recorded := r.recorded(key)
+		r.fail(NewReplayMismatchError("condition", node, name, recorded, recorded+1))
+		return false
+	}
+	r.conditions[key] = conditions[1:]
+	return conditions[0].Outcome
+}
+
+func (r *Replayer) end(result *_Result) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, call := range r.recording.Calls {
+		key := replayKey{call.Node, call.Functor}
+		if remaining := len(r.calls[key]); remaining != 0 {
+			recorded, _ := r.recorded(key)
+			r.fail(NewReplayMismatchError("functor", call.Node, call.Functor, recorded, recorded-remaining))
+		}
+	}
+	for _, condition := range r.recording.Conditions {
+		key := replayKey{condition.Node, condition.Condition}
+		if remaining := len(r.conditions[key]); remaining != 0 {
+			_, recorded := r.recorded(key)
+			r.fail(NewReplayMismatchError("condition", condition.Node, condition.Condition, recorded,
+				recorded-remaining))
+		}
+	}
+}
+
+// SetRecorder records the calls of the functors and the outcomes of the conditions of every Wait of the flow
+func (f *FlowEngine) SetRecorder(recorder *Recorder) *FlowEngine {
+	f.tape = nil
+	if recorder != nil {
+		f.tape = recorder
+	}
+	return f
+}
+
+func (e *ElseFlowEngine) SetRecorder(recorder *Recorder) *ElseFlowEngine {
+	e.invoker.SetRecorder(recorder)
+	return e
+}
+
+// SetReplayer makes every Wait of the flow replay the recording of the replayer instead of calling the functors and
+// the conditions
+func (f *FlowEngine) SetReplayer(replayer *Replayer) *FlowEngine {
+	f.tape = nil
+	if replayer != nil {
+		f.tape = replayer
+	}
+	return f
+}
+
+func (e *ElseFlowEngine) SetReplayer(replayer *Replayer) *ElseFlowEngine {
+	e.invoker.SetReplayer(replayer)
+	return e
+}
@@ -0,0 +1,131 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+)
+
+// respond returns functors which all have the same name
+func respond(err error) ICallable {
+	return func(_data *_Data) *_Result {
+		if err != nil {
+			return &_Result{Err: err}
+		}
+		return nil
+	}
+}
+
+func record(t *testing.T, flow *FlowEngine) *Recording {
+	t.Helper()
+	recorder := NewRecorder()
+	flow.SetRecorder(recorder).Wait()
+	recording, err := recorder.Recording()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recording
+}
+
+func TestRecorderKeepsThePositionOfTheNodes(t *testing.T) {
+	recording := record(t, NewFlow().Do(respond(nil)).
+		IfSubPath(always, NewFlow().Do(respond(nil)).Do(respond(nil))).
+		Do(respond(nil)))
+	if len(recording.Calls) != 4 || len(recording.Conditions) != 1 {
+		t.Fatalf("expected 4 calls and 1 condition, got %d and %d", len(recording.Calls), len(recording.Conditions))
+	}
+	for index, node := range []string{"0", "1.0.0", "1.0.1", "2"} {
+		if recording.Calls[index].Node != node {
+			t.Errorf("expected call %d to be made by node %s, got %s", index, node, recording.Calls[index].Node)
+		}
+	}
+	if recording.Conditions[0].Node != "1" {
+		t.Errorf("expected the condition to be evaluated by node 1, got %s", recording.Conditions[0].Node)
+	}
+}
+
+func TestReplayerMatchesTheCallsByNode(t *testing.T) {
+	build := func() *FlowEngine {
+		return NewFlow().Do(respond(nil)).Do(respond(errTest))
+	}
+	recording := record(t, build())
+	// The calls of concurrent nodes, such as the steps of a DAG, are recorded in any order
+	recording.Calls[0], recording.Calls[1] = recording.Calls[1], recording.Calls[0]
+
+	replayer := NewReplayer(recording)
+	flow := build().SetReplayer(replayer)
+	result := flow.Wait()
+	if recorded, ok := result.Err.(*RecordedError); !ok || recorded.Msg != errTest.Error() {
+		t.Errorf("expected the recorded failure of the second node, got %v", result.Err)
+	}
+	if states := []NodeState{flow.nodes[0].GetState(), flow.nodes[1].GetState()}; states[0] != SucceededNodeState ||
+		states[1] != FailedNodeState {
+		t.Errorf("expected each node to replay its own call, got %s and %s", states[0], states[1])
+	}
+	if err := replayer.Err(); err != nil {
+		t.Errorf("unexpected mismatch %v", err)
+	}
+}
+
+func TestReplayerReportsTheMismatches(t *testing.T) {
+	recording := record(t, NewFlow().Do(respond(nil)).Do(respond(nil)))
+
+	replayer := NewReplayer(recording)
+	result := NewFlow().Do(respond(nil)).Do(respond(nil)).Do(respond(nil)).SetReplayer(replayer).Wait()
+	mismatch, ok := result.Err.(*ReplayMismatchError)
+	if !ok || mismatch.Node != "2" || mismatch.Recorded != 0 || mismatch.Replayed != 1 {
+		t.Fatalf("expected the call of the third node to be a mismatch, got %v", result.Err)
+	}
+	if replayer.Err() != mismatch {
+		t.Errorf("expected Err to return the mismatch, got %v", replayer.Err())
+	}
+
+	replayer = NewReplayer(recording)
+	NewFlow().Do(respond(nil)).SetReplayer(replayer).Wait()
+	if mismatch, ok := replayer.Err().(*ReplayMismatchError); !ok || mismatch.Node != "1" || mismatch.Replayed != 0 {
+		t.Errorf("expected the call of the second node to be missing, got %v", replayer.Err())
+	}
+}
+
+func TestReplayerRestoresTheDataFromItsZeroValue(t *testing.T) {
+	recording := record(t, NewFlow().Do(mark("first")))
+
+	ctx := context.WithValue(context.Background(), contextKey{}, "replay")
+	flow := NewFlow().Do(mark("first")).SetReplayer(NewReplayer(recording))
+	flow.getData().FunctionName = "stale;"
+	SetContext(flow.getData(), ctx)
+	if result := flow.Wait(); result.Err != nil {
+		t.Fatalf("unexpected failure %v", result.Err)
+	}
+	if flow.getData().FunctionName != "" {
+		t.Errorf("expected the fields left out of the recording to be reset, got %q", flow.getData().FunctionName)
+	}
+	if GetContext(flow.getData()) != ctx {
+		t.Error("expected the context of the data to be kept")
+	}
+}
+
+func TestReplayerReportsMalformedRecordings(t *testing.T) {
+	recording := record(t, NewFlow().Do(respond(nil)))
+	recording.Calls[0].Data = []byte(`{"broken"`)
+	replayer := NewReplayer(recording)
+	result := NewFlow().Do(respond(nil)).SetReplayer(replayer).Wait()
+	if result.Err == nil || replayer.Err() != result.Err {
+		t.Errorf("expected the error decoding the call to be reported, got %v and %v", result.Err, replayer.Err())
+	}
+
+	recording = record(t, NewFlow().Do(respond(errTest)))
+	recording.Calls[0].Result.Result = []byte(`[]`)
+	replayer = NewReplayer(recording)
+	result = NewFlow().Do(respond(errTest)).SetReplayer(replayer).Wait()
+	if result.Err == nil || replayer.Err() != result.Err {
+		t.Errorf("expected the error decoding the result to be reported, got %v and %v", result.Err, replayer.Err())
+	}
+
+	recording = record(t, NewFlow().Do(respond(nil)))
+	recording.Data = []byte(`{"broken"`)
+	replayer = NewReplayer(recording)
+	NewFlow().Do(respond(nil)).SetReplayer(replayer).Wait()
+	if replayer.Err() == nil {
+		t.Error("expected the error decoding the initial data to be reported")
+	}
+}
@@ -0,0 +1,435 @@
+package goflow
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultRegistry is used by every new flow to look up the functors and conditions given by name, e.g. DoNamed("charge-card")
+var DefaultRegistry = NewRegistry()
+
+// Registry gives stable names to the functors, conditions and prepare functions so that flows can be described
+// outside of the code.
+type Registry struct {
+	mutex      sync.RWMutex
+	callables  map[string]ICallable
+	conditions map[string]IBoolFunc
+	prepares   map[string]IPrepareFunc
+	rateLimits map[string]IRateLimiter
+	breakers   map[string]*CircuitBreaker
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		callables:  make(map[string]ICallable),
+		conditions: make(map[string]IBoolFunc),
+		prepares:   make(map[string]IPrepareFunc),
+		rateLimits: make(map[string]IRateLimiter),
+		breakers:   make(map[string]*CircuitBreaker),
+	}
+}
+
+// DuplicateNameError is returned when a name is registered twice
+type DuplicateNameError struct {
+	Kind string
+	Name string
+}
+
+func NewDuplicateNameError(kind string, name string) *DuplicateNameError {
+	return &DuplicateNameError{Kind: kind, Name: name}
+}
+
+func (d *DuplicateNameError) Error() string {
+	return fmt.Sprintf("%s %q is already registered", d.Kind, d.Name)
+}
+
+func (r *Registry) RegisterCallable(name string, functor ICallable) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.callables[name]; ok {
+		return NewDuplicateNameError("callable", name)
+	}
+	r.callables[name] = functor
+	return nil
+}
+
+func (r *Registry) RegisterCondition(name string, condition IBoolFunc) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.conditions[name]; ok {
+		return NewDuplicateNameError("condition", name)
+	}
+	r.conditions[name] = condition
+	return nil
+}
+
+func (r *Registry) RegisterPrepare(name string, prepareFunc IPrepareFunc) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.prepares[name]; ok {
+		return NewDuplicateNameError("prepare function", name)
+	}
+	r.prepares[name] = prepareFunc
+	return nil
+}
+
+func (r *Registry) GetCallable(name string) (ICallable, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	functor, ok := r.callables[name]
+	return functor, ok
+}
+
+func (r *Registry) GetCondition(name string) (IBoolFunc, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	condition, ok := r.conditions[name]
+	return condition, ok
+}
+
+func (r *Registry) GetPrepare(name string) (IPrepareFunc, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	prepareFunc, ok := r.prepares[name]
+	return prepareFunc, ok
+}
+
+// nodeNames are the names the functors, the condition and the compensation of a node were given by to the builder
+// methods. They are empty for the ones given as functions.
+type nodeNames struct {
+	functors     []string
+	condition    string
+	compensation string
+}
+
+func (n *nodeNames) functor(index int) string {
+	if index < len(n.functors) {
+		return n.functors[index]
+	}
+	return ""
+}
+
+// displayName is the name a function was given by, or its name in the program if it was given as a function
+func displayName(name string, function interface{}) string {
+	if name != "" {
+		return name
+	}
+	return functionName(function)
+}
+
+func functorNames(node IBasicFlowNode, functors []ICallable) []string {
+	names := make([]string, 0, len(functors))
+	for index, functor := range functors {
+		names = append(names, displayName(node.getNames().functor(index), functor))
+	}
+	return names
+}
+
+func prepareNames(node IBasicFlowNode, prepareFuncs []IPrepareFunc) []string {
+	names := make([]string, 0, len(prepareFuncs))
+	for index, prepareFunc := range prepareFuncs {
+		names = append(names, displayName(node.getNames().functor(index), prepareFunc))
+	}
+	return names
+}
+
+func conditionName(node IBasicFlowNode, condition IBoolFunc) string {
+	return displayName(node.getNames().condition, condition)
+}
+
+// SetRateLimit makes the callable registered as name wait for a token of the limiter before every call, in every node
+// of the flows using this registry where it is given by name. The limiter is removed if it is nil.
+func (r *Registry) SetRateLimit(name string, limiter IRateLimiter) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.callables[name]; !ok {
+		return NewFunctorNotFoundError(name)
+	}
+	if limiter == nil {
+		delete(r.rateLimits, name)
+	} else {
+		r.rateLimits[name] = limiter
+	}
+	return nil
+}
+
+// rateLimitOf finds the rate limiter of the functor registered as name, an empty name is a functor given as a function
+func (r *Registry) rateLimitOf(name string) IRateLimiter {
+	if r == nil || name == "" {
+		return nil
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.rateLimits[name]
+}
+
+// SetCircuitBreaker makes every call of the callable registered as name go through the breaker, in every node of the
+// flows using this registry where it is given by name. The breaker is removed if it is nil.
+func (r *Registry) SetCircuitBreaker(name string, breaker *CircuitBreaker) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.callables[name]; !ok {
+		return NewFunctorNotFoundError(name)
+	}
+	if breaker == nil {
+		delete(r.breakers, name)
+	} else {
+		r.breakers[name] = breaker
+	}
+	return nil
+}
+
+func (r *Registry) circuitBreakerOf(name string) *CircuitBreaker {
+	if r == nil || name == "" {
+		return nil
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.breakers[name]
+}
+
+func (r *Registry) ListCallables() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	names := make([]string, 0, len(r.callables))
+	for name := range r.callables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Registry) ListConditions() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	names := make([]string, 0, len(r.conditions))
+	for name := range r.conditions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Registry) ListPrepares() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	names := make([]string, 0, len(r.prepares))
+	for name := range r.prepares {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// callablesNamed looks up the callables of the named builder methods. A name which is not registered becomes a functor
+// failing with FunctorNotFoundError.
+func (r *Registry) callablesNamed(names []string) []ICallable {
+	res := make([]ICallable, 0, len(names))
+	for _, name := range names {
+		res = append(res, r.callableNamed(name))
+	}
+	return res
+}
+
+func (r *Registry) callableNamed(name string) ICallable {
+	if callable, ok := r.GetCallable(name); ok {
+		return callable
+	}
+	return notFoundCallable(name)
+}
+
+func (r *Registry) preparesNamed(names []string) []IPrepareFunc {
+	res := make([]IPrepareFunc, 0, len(names))
+	for _, name := range names {
+		if prepare, ok := r.GetPrepare(name); ok {
+			res = append(res, prepare)
+		} else {
+			res = append(res, notFoundPrepare(name))
+		}
+	}
+	return res
+}
+
+// conditionNamed returns nil for a condition which is not registered, which makes the node fail with
+// ConditionNotFoundError naming it
+func (r *Registry) conditionNamed(name string) IBoolFunc {
+	condition, _ := r.GetCondition(name)
+	return condition
+}
+
+func notFoundCallable(name string) ICallable {
+	return func(_data *_Data) *_Result {
+		return &_Result{Err: NewFunctorNotFoundError(name)}
+	}
+}
+
+func notFoundPrepare(name string) IPrepareFunc {
+	return func(_data *_Data, input _PrepareInput) *_Result {
+		return &_Result{Err: NewFunctorNotFoundError(name)}
+	}
+}
+
+// nameLast keeps the names the last node was built with
+func nameLast(nodes []IBasicFlowNode, functors []string, condition string) {
+	names := nodes[len(nodes)-1].getNames()
+	names.functors, names.condition = functors, condition
+}
+
+// PrepareNamed is Prepare with the names the prepare functions are registered with in the registry of the flow. A name
+// which is not registered makes the node fail with FunctorNotFoundError.
+func (f *FlowEngine) PrepareNamed(input _PrepareInput, names ...string) *FlowEngine {
+	f.Prepare(input, f.registry.preparesNamed(names)...)
+	nameLast(f.nodes, names, "")
+	return f
+}
+
+// DoNamed is Do with the names the callables are registered with in the registry of the flow. A name which is not
+// registered makes the node fail with FunctorNotFoundError.
+func (f *FlowEngine) DoNamed(names ...string) *FlowEngine {
+	f.Do(f.registry.callablesNamed(names)...)
+	nameLast(f.nodes, names, "")
+	return f
+}
+
+func (f *FlowEngine) ForNamed(times int, names ...string) *FlowEngine {
+	f.For(times, f.registry.callablesNamed(names)...)
+	nameLast(f.nodes, names, "")
+	return f
+}
+
+func (f *FlowEngine) ParallelNamed(names ...string) *FlowEngine {
+	f.Parallel(f.registry.callablesNamed(names)...)
+	nameLast(f.nodes, names, "")
+	return f
+}
+
+func (f *FlowEngine) RaceNamed(names ...string) *FlowEngine {
+	f.Race(f.registry.callablesNamed(names)...)
+	nameLast(f.nodes, names, "")
+	return f
+}
+
+func (f *FlowEngine) QuorumNamed(needed int, names ...string) *FlowEngine {
+	f.Quorum(needed, f.registry.callablesNamed(names)...)
+	nameLast(f.nodes, names, "")
+	return f
+}
+
+// IfNamed is If with the names of a registered condition and callables. A condition which is not registered makes the
+// node fail with ConditionNotFoundError.
+func (f *FlowEngine) IfNamed(condition string, names ...string) *ElseFlowEngine {
+	res := f.If(f.registry.conditionNamed(condition), f.registry.callablesNamed(names)...)
+	nameLast(f.nodes, names, condition)
+	return res
+}
+
+func (f *FlowEngine) IfSubPathNamed(condition string, subPath IFlowEngine) *ElseFlowEngine {
+	res := f.IfSubPath(f.registry.conditionNamed(condition), subPath)
+	nameLast(f.nodes, nil, condition)
+	return res
+}
+
+func (f *FlowEngine) FinallyNamed(names ...string) *FlowEngine {
+	f.Finally(f.registry.callablesNamed(names)...)
+	nameLast(f.nodes, names, "")
+	return f
+}
+
+// CompensateNamed is Compensate with the name of a registered callable
+func (f *FlowEngine) CompensateNamed(name string) *FlowEngine {
+	f.Compensate(f.registry.callableNamed(name))
+	if len(f.nodes) != 0 {
+		f.nodes[len(f.nodes)-1].getNames().compensation = name
+	}
+	return f
+}
+
+func (e *ElseFlowEngine) PrepareNamed(input _PrepareInput, names ...string) *FlowEngine {
+	e.Prepare(input, e.invoker.registry.preparesNamed(names)...)
+	nameLast(*e.nodes, names, "")
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) DoNamed(names ...string) *FlowEngine {
+	e.Do(e.invoker.registry.callablesNamed(names)...)
+	nameLast(*e.nodes, names, "")
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) ForNamed(times int, names ...string) *FlowEngine {
+	e.For(times, e.invoker.registry.callablesNamed(names)...)
+	nameLast(*e.nodes, names, "")
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) ParallelNamed(names ...string) *FlowEngine {
+	e.Parallel(e.invoker.registry.callablesNamed(names)...)
+	nameLast(*e.nodes, names, "")
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) RaceNamed(names ...string) *FlowEngine {
+	e.Race(e.invoker.registry.callablesNamed(names)...)
+	nameLast(*e.nodes, names, "")
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) QuorumNamed(needed int, names ...string) *FlowEngine {
+	e.Quorum(needed, e.invoker.registry.callablesNamed(names)...)
+	nameLast(*e.nodes, names, "")
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) IfNamed(condition string, names ...string) *ElseFlowEngine {
+	res := e.If(e.invoker.registry.conditionNamed(condition), e.invoker.registry.callablesNamed(names)...)
+	nameLast(*e.nodes, names, condition)
+	return res
+}
+
+func (e *ElseFlowEngine) ElseIfNamed(condition string, names ...string) *ElseFlowEngine {
+	e.ElseIf(e.invoker.registry.conditionNamed(condition), e.invoker.registry.callablesNamed(names)...)
+	nameLast(*e.nodes, names, condition)
+	return e
+}
+
+func (e *ElseFlowEngine) ElseNamed(names ...string) *FlowEngine {
+	e.Else(e.invoker.registry.callablesNamed(names)...)
+	nameLast(*e.nodes, names, "")
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) IfSubPathNamed(condition string, subPath IFlowEngine) *ElseFlowEngine {
+	res := e.IfSubPath(e.invoker.registry.conditionNamed(condition), subPath)
+	nameLast(*e.nodes, nil, condition)
+	return res
+}
+
+func (e *ElseFlowEngine) ElseIfSubPathNamed(condition string, subPath IFlowEngine) *ElseFlowEngine {
+	e.ElseIfSubPath(e.invoker.registry.conditionNamed(condition), subPath)
+	nameLast(*e.nodes, nil, condition)
+	return e
+}
+
+func (e *ElseFlowEngine) FinallyNamed(names ...string) *FlowEngine {
+	e.Finally(e.invoker.registry.callablesNamed(names)...)
+	nameLast(*e.nodes, names, "")
+	return e.invoker
+}
+
+func (e *ElseFlowEngine) CompensateNamed(name string) *ElseFlowEngine {
+	e.Compensate(e.invoker.registry.callableNamed(name))
+	if len(*e.nodes) != 0 {
+		(*e.nodes)[len(*e.nodes)-1].getNames().compensation = name
+	}
+	return e
+}
@@ -0,0 +1,208 @@
+package goflow
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingLimiter lets every call through and counts them
+type countingLimiter struct {
+	mutex sync.Mutex
+	waits int
+}
+
+func (c *countingLimiter) Wait(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.waits++
+	return nil
+}
+
+func TestRegistryRefusesDuplicates(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.RegisterCallable("succeed", succeed); err != nil {
+		t.Fatal(err)
+	}
+	err := registry.RegisterCallable("succeed", fail)
+	if duplicate, ok := err.(*DuplicateNameError); !ok || duplicate.Name != "succeed" {
+		t.Errorf("expected the name to be refused, got %v", err)
+	}
+	if functor, _ := registry.GetCallable("succeed"); functor(&_Data{}) != nil {
+		t.Error("the refused function must not replace the registered one")
+	}
+	if err := registry.RegisterCondition("always", always); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.RegisterCondition("always", never); err == nil {
+		t.Error("expected the condition name to be refused")
+	}
+}
+
+func TestRegistryTellsClosuresApart(t *testing.T) {
+	registry := NewRegistry()
+	for _, name := range []string{"first", "second", "third"} {
+		if err := registry.RegisterCallable(name, mark(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := strings.Join(registry.ListCallables(), ","); names != "first,second,third" {
+		t.Errorf("unexpected names %s", names)
+	}
+
+	flow := NewFlow().SetRegistry(registry).DoNamed("second", "first").ParallelNamed("third")
+	assertContains(t, flow.Plan().String(), "Do: second, first\n", "Parallel: third\n")
+	assertContains(t, flow.ToDOT(), `label="Do\nsecond\nfirst"`, `label="third"`)
+	if result := flow.Wait(); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "second;first;third;" {
+		t.Errorf("unexpected calls %s", data)
+	}
+
+	// A closure capturing nothing is the same value every time, it can still be registered under several names
+	noop := func() ICallable {
+		return func(_data *_Data) *_Result {
+			return nil
+		}
+	}
+	if err := registry.RegisterCallable("noop", noop()); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.RegisterCallable("other-noop", noop()); err != nil {
+		t.Errorf("expected the same function to be registered under another name, got %v", err)
+	}
+}
+
+func TestRegistryLimitsFunctorsGivenByName(t *testing.T) {
+	registry := NewRegistry()
+	limiter, breaker := &countingLimiter{}, NewCircuitBreaker("first", 0.5, 2, 0)
+	errs := []error{
+		registry.RegisterCallable("first", mark("first")),
+		registry.RegisterCallable("second", mark("second")),
+		registry.SetRateLimit("first", limiter),
+		registry.SetCircuitBreaker("first", breaker),
+	}
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := registry.SetRateLimit("missing", limiter); err == nil {
+		t.Error("expected a limit of an unknown callable to be refused")
+	}
+
+	first, _ := registry.GetCallable("first")
+	NewFlow().SetRegistry(registry).DoNamed("first", "second", "first").Do(first).Wait()
+	if limiter.waits != 2 {
+		t.Errorf("expected the two calls by name to be limited, got %d", limiter.waits)
+	}
+	if state := breaker.State(); state != ClosedCircuitState {
+		t.Errorf("expected the breaker to stay closed, got %s", state)
+	}
+}
+
+func TestNamedBuildersReportMissingNames(t *testing.T) {
+	registry := NewRegistry()
+	result := NewFlow().SetRegistry(registry).DoNamed("missing").Wait()
+	if notFound, ok := result.Err.(*FunctorNotFoundError); !ok || notFound.Name != "missing" {
+		t.Errorf("expected FunctorNotFoundError, got %v", result.Err)
+	}
+
+	result = NewFlow().SetRegistry(registry).IfNamed("unknown").ElseNamed().Wait()
+	notFound, ok := result.Err.(*ConditionNotFoundError)
+	if !ok || notFound.Name != "unknown" || notFound.Error() != "condition unknown is not found" {
+		t.Errorf("expected ConditionNotFoundError naming the condition, got %v", result.Err)
+	}
+	if err := NewConditionNotFoundError(); err.Error() != "condition is nil" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
+
+func TestNamedBuildersBuildEveryNode(t *testing.T) {
+	registry := newDefinitionRegistry(t)
+	flow := NewFlow().SetRegistry(registry).
+		PrepareNamed(_PrepareInput{}, "load").
+		IfNamed("is-vip", "vip").ElseIfNamed("is-member", "member").ElseNamed("other").
+		ForNamed(2, "first").
+		RaceNamed("second").
+		QuorumNamed(1, "first").
+		IfSubPathNamed("is-vip", NewFlow().SetRegistry(registry).DoNamed("vip")).
+		ElseIfSubPathNamed("is-member", NewFlow().SetRegistry(registry).DoNamed("member")).
+		FinallyNamed("last").CompensateNamed("other")
+	if result := flow.Wait(); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "load;member;first;first;second;first;member;last;" {
+		t.Errorf("unexpected calls %s", data)
+	}
+	expected := `Prepare: load
+If is-vip: vip
+ElseIf is-member: member
+Else: other
+For 2 times: first
+Race: second
+Quorum 1 of 1: first
+IfSubPath is-vip
+    Do: vip
+ElseIfSubPath is-member
+    Do: member
+Finally: last
+`
+	if plan := flow.Plan().String(); plan != expected {
+		t.Errorf("unexpected plan:\n%s", plan)
+	}
+
+	flow.Compensate(succeed)
+	if name := flow.getNodes()[len(flow.getNodes())-1].getNames().compensation; name != "" {
+		t.Errorf("expected Compensate to forget the name, got %s", name)
+	}
+}
+
+func TestNewFlowDefinitionRoundTrips(t *testing.T) {
+	registry := newDefinitionRegistry(t)
+	flow, err := LoadFlow([]byte(orderDefinition), registry, _PrepareInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	definition, err := NewFlowDefinition(flow, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := definition.ToYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ParseFlowDefinition(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := loaded.Build(registry, _PrepareInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow.Plan().String() != reloaded.Plan().String() {
+		t.Errorf("expected the same flow, got:\n%s\ninstead of:\n%s", reloaded.Plan(), flow.Plan())
+	}
+	assertContains(t, string(encoded), "- do:\n      - first\n      - second\n    note: check the stock\n",
+		"- elseif: is-member\n    subpath:\n      - do:\n          - member\n")
+
+	encoded, err = definition.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(encoded), `"elseif": "is-member"`, `"prepare": [`)
+}
+
+func TestNewFlowDefinitionNeedsNames(t *testing.T) {
+	registry := newDefinitionRegistry(t)
+	first, _ := registry.GetCallable("first")
+	flow := NewFlow().SetRegistry(registry).Do(first).DoNamed("first").IfNamed("is-vip", "unknown").Else(succeed)
+	_, err := NewFlowDefinition(flow, registry)
+	if err == nil {
+		t.Fatal("expected the functions given by value to be refused")
+	}
+	assertContains(t, err.Error(), "callable goflow.mark.func1 is not given by name", `callable "unknown" is not registered`,
+		"callable goflow.succeed is not given by name")
+}
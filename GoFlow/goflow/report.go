@@ -0,0 +1,91 @@
+package goflow
+
+// ExecutionReport is a snapshot of the state of every node in a flow, including the nodes of its sub-paths.
+// The states are the ones left by the last Wait of the flow.
+type ExecutionReport struct {
+	States map[IBasicFlowNode]NodeState
+}
+
+func NewExecutionReport(engine IFlowEngine) *ExecutionReport {
+	report := &ExecutionReport{States: make(map[IBasicFlowNode]NodeState)}
+	walkNodes(engine, 0, func(node IBasicFlowNode, depth int) {
+		report.States[node] = node.GetState()
+	})
+	return report
+}
+
+func (r *ExecutionReport) GetState(node IBasicFlowNode) NodeState {
+	if r == nil {
+		return NotRunNodeState
+	}
+	return r.States[node]
+}
+
+func (f *FlowEngine) Report() *ExecutionReport {
+	return NewExecutionReport(f)
+}
+
+func (e *ElseFlowEngine) Report() *ExecutionReport {
+	return NewExecutionReport(e)
+}
+
+func (s NodeState) String() string {
+	switch s {
+	case NotRunNodeState:
+		return "NotRun"
+	case SkippedNodeState:
+		return "Skipped"
+	case RunningNodeState:
+		return "Running"
+	case NotTakenNodeState:
+		return "NotTaken"
+	case SucceededNodeState:
+		return "Succeeded"
+	case FailedNodeState:
+		return "Failed"
+	case ReturnedNodeState:
+		return "Returned"
+	case CachedNodeState:
+		return "Cached"
+	}
+	return "Unknown"
+}
+
+// subPathsOf returns the sub-flows owned by a node, or nil if the node has none.
+func subPathsOf(node IBasicFlowNode) []IFlowEngine {
+	switch n := node.(type) {
+	case *IfSubPathNode:
+		return []IFlowEngine{n.SubPath}
+	case *ElseIfSubPathNode:
+		return []IFlowEngine{n.SubPath}
+	case *ElseSubPathNode:
+		return []IFlowEngine{n.SubPath}
+	case *FinallySubPathNode:
+		return []IFlowEngine{n.SubPath}
+	case *TrySubPathNode:
+		return []IFlowEngine{n.SubPath}
+	case *RaceSubPathNode:
+		return n.SubPaths
+	}
+	return nil
+}
+
+// walkNodes visits the nodes of the engine in order, descending into the sub-paths right after the node owning them.
+func walkNodes(engine IFlowEngine, depth int, visit func(node IBasicFlowNode, depth int)) {
+	if engine == nil {
+		return
+	}
+	for _, node := range engine.getNodes() {
+		visit(node, depth)
+		for _, subPath := range subPathsOf(node) {
+			walkNodes(subPath, depth+1, visit)
+		}
+	}
+}
+
+func resetNodeStates(engine IFlowEngine) {
+	walkNodes(engine, 0, func(node IBasicFlowNode, depth int) {
+		node.SetState(NotRunNodeState)
+		node.SetShouldSkip(false)
+	})
+}
@@ -0,0 +1,49 @@
+package goflow
+
+import "testing"
+
+func TestReportKeepsStateOfEveryNode(t *testing.T) {
+	subPath := NewFlow().Do(succeed)
+	flow := NewFlow().Do(succeed).If(never, succeed).ElseIfSubPath(always, subPath).Do(fail).Do(succeed)
+	flow.Wait()
+	report := flow.Report()
+
+	nodes := flow.getNodes()
+	expected := []NodeState{SucceededNodeState, NotTakenNodeState, SucceededNodeState, FailedNodeState,
+		SkippedNodeState}
+	for index, state := range expected {
+		if actual := report.GetState(nodes[index]); actual != state {
+			t.Errorf("node %d: expected %s, got %s", index, state, actual)
+		}
+	}
+	if state := report.GetState(subPath.getNodes()[0]); state != SucceededNodeState {
+		t.Errorf("expected the node of the sub-path to succeed, got %s", state)
+	}
+	if len(report.States) != 6 {
+		t.Errorf("expected 6 nodes in the report, got %d", len(report.States))
+	}
+}
+
+func TestWaitResetsStatesOfLastRun(t *testing.T) {
+	taken := true
+	condition := func(_data *_Data) bool { return taken }
+	flow := NewFlow().If(condition, succeed).Else(succeed)
+	flow.Wait()
+	if state := flow.Report().GetState(flow.getNodes()[1]); state != SkippedNodeState {
+		t.Fatalf("expected the Else to be skipped, got %s", state)
+	}
+
+	taken = false
+	*flow.getResult() = &_Result{}
+	flow.Wait()
+	if state := flow.Report().GetState(flow.getNodes()[1]); state != SucceededNodeState {
+		t.Errorf("expected the Else to run once the If is not taken, got %s", state)
+	}
+}
+
+func TestNilReportHasNoState(t *testing.T) {
+	var report *ExecutionReport
+	if state := report.GetState(nil); state != NotRunNodeState {
+		t.Errorf("expected NotRun, got %s", state)
+	}
+}
@@ -0,0 +1,20 @@
+package goflow
+
+// Return is returned by a functor to end the current flow, or the current sub-path, successfully. The rest of the
+// nodes are skipped except the finally nodes, and the node is reported with ReturnedNodeState.
+var Return = &_Result{StatusMsg: "return"}
+
+// returnedBefore tells whether a node before start has ended the flow, which is the case when resuming a flow after
+// its Return
+func returnedBefore(nodes []IBasicFlowNode, start int) bool {
+	for index := 0; index < start && index < len(nodes); index++ {
+		if nodes[index].GetState() == ReturnedNodeState {
+			return true
+		}
+	}
+	return false
+}
+
+func runsAfterReturn(node IBasicFlowNode) bool {
+	return node.GetNodeType() == FinallyNodeType || node.GetNodeType() == FinallySubPathNodeType
+}
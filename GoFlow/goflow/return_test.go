@@ -0,0 +1,56 @@
+package goflow
+
+import "testing"
+
+func returnEarly(_data *_Data) *_Result {
+	_data.FunctionName += "return;"
+	return Return
+}
+
+func TestReturnEndsFlowSuccessfully(t *testing.T) {
+	succeeded := false
+	flow := NewFlow().Do(mark("first")).Do(returnEarly, mark("skipped")).Do(mark("skipped")).
+		Finally(mark("finally")).OnSuccess(func(_data *_Data, _result *_Result) {
+		succeeded = true
+	})
+
+	if result := flow.Wait(); result.Err != nil || result.StatusCode != 0 || result == Return {
+		t.Fatalf("expected the flow to succeed, got %+v", result)
+	}
+	if data := flow.getData().FunctionName; data != "first;return;finally;" {
+		t.Errorf("unexpected calls %s", data)
+	}
+	if !succeeded {
+		t.Error("expected OnSuccess to run")
+	}
+	states := []NodeState{SucceededNodeState, ReturnedNodeState, SkippedNodeState, SucceededNodeState}
+	for index, state := range states {
+		if actual := flow.Report().GetState(flow.getNodes()[index]); actual != state {
+			t.Errorf("node %d: expected %s, got %s", index, state, actual)
+		}
+	}
+}
+
+func TestReturnEndsSubPathOnly(t *testing.T) {
+	flow := NewFlow().IfSubPath(always, NewFlow().Do(returnEarly).Do(mark("skipped"))).Do(mark("after"))
+	if result := flow.Wait(); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "return;after;" {
+		t.Errorf("expected the flow to go on after the sub-path, got %s", data)
+	}
+}
+
+func TestReturnFromParallelAndCatch(t *testing.T) {
+	flow := NewFlow().Parallel(returnEarly, succeed).Do(mark("skipped"))
+	if result := flow.Wait(); result.Err != nil || flow.getData().FunctionName != "return;" {
+		t.Errorf("expected the Parallel to return, got %v, %s", result.Err, flow.getData().FunctionName)
+	}
+
+	flow = NewFlow().Do(fail).Catch(nil, func(_data *_Data, _result *_Result) *_Result {
+		return Return
+	}).Do(mark("skipped"))
+	if result := flow.Wait(); result.Err != nil || flow.getData().FunctionName != "" {
+		t.Errorf("expected the Catch to return, got %v, %s", result.Err, flow.getData().FunctionName)
+	}
+}
@@ -0,0 +1,479 @@
+package goflow
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ISchedule tells when a job runs next, the zero time meaning never again
+type ISchedule interface {
+	Next(after time.Time) time.Time
+}
+
+// IFlowFactory builds the flow of one run, flows can not be run twice
+type IFlowFactory = func() IFlowEngine
+
+type OverlapPolicy int64
+
+const (
+	// OverlapSkip drops a run which is due while the previous one is still running
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue starts a run which is due while the previous one is still running once it has finished
+	OverlapQueue
+	// OverlapAllow starts every run when it is due, whether the previous one is still running or not
+	OverlapAllow
+)
+
+func (o OverlapPolicy) String() string {
+	switch o {
+	case OverlapSkip:
+		return "Skip"
+	case OverlapQueue:
+		return "Queue"
+	case OverlapAllow:
+		return "Allow"
+	}
+	return "Unknown"
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// Every runs a job at a fixed interval, the first run being one interval after the scheduler starts
+func Every(interval time.Duration) ISchedule {
+	return intervalSchedule{interval: interval}
+}
+
+func (i intervalSchedule) Next(after time.Time) time.Time {
+	if i.interval <= 0 {
+		return time.Time{}
+	}
+	return after.Add(i.interval)
+}
+
+type CronSyntaxError struct {
+	Expr   string
+	Reason string
+}
+
+func NewCronSyntaxError(expr string, reason string) *CronSyntaxError {
+	return &CronSyntaxError{Expr: expr, Reason: reason}
+}
+
+func (c *CronSyntaxError) Error() string {
+	return fmt.Sprintf("cron expression %q: %s", c.Expr, c.Reason)
+}
+
+// SchedulerShutdownError is returned when a job is added to a scheduler which is shut down
+type SchedulerShutdownError struct {
+	Job string
+}
+
+func NewSchedulerShutdownError(job string) *SchedulerShutdownError {
+	return &SchedulerShutdownError{Job: job}
+}
+
+func (s *SchedulerShutdownError) Error() string {
+	return fmt.Sprintf("job %s is added to a scheduler which is shut down", s.Job)
+}
+
+// CronSchedule runs a job at the minutes matching a cron expression, in the location of the time given to Next
+type CronSchedule struct {
+	Expr    string
+	minutes map[int]bool
+	hours   map[int]bool
+	days    map[int]bool
+	months  map[int]bool
+	weekday map[int]bool
+	// anyDay and anyWeekday tell whether the day of month and the day of week are "*". If both are restricted, a
+	// day matching either of them matches, as in cron.
+	anyDay     bool
+	anyWeekday bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses the 5 fields of a cron expression: minute, hour, day of month, month and day of week, where Sunday
+// is 0 or 7. A field is "*" or a list of values and ranges, each with an optional "/step". The descriptors @yearly,
+// @monthly, @weekly, @daily and @hourly are accepted as well.
+func ParseCron(expr string) (*CronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if descriptor, ok := cronDescriptors[spec]; ok {
+		spec = descriptor
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, NewCronSyntaxError(expr, fmt.Sprintf("expected 5 fields, got %d", len(fields)))
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := make([]map[int]bool, 5)
+	for index, field := range fields {
+		set, err := parseCronField(field, bounds[index][0], bounds[index][1])
+		if err != nil {
+			return nil, NewCronSyntaxError(expr, err.Error())
+		}
+		sets[index] = set
+	}
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &CronSchedule{
+		Expr:       expr,
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekday:    sets[4],
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			value, err := strconv.Atoi(part[index+1:])
+			if err != nil || value < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			step, part = value, part[:index]
+		}
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			value, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			low, high = value, value
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step != 1 {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("%q is out of the range %d-%d", part, min, max)
+		}
+		for value := low; value <= high; value += step {
+			set[value] = true
+		}
+	}
+	return set, nil
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekday[int(t.Weekday())]
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+// Next returns the first matching minute after the given time, or the zero time if there is none in the next 5 years
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.months[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// RunRecord is one run of a scheduled job. Skipped runs were dropped by OverlapSkip and have no result.
+type RunRecord struct {
+	Job       string
+	Scheduled time.Time
+	Started   time.Time
+	Finished  time.Time
+	Skipped   bool
+	Result    *_Result
+}
+
+// ScheduledJob is a flow factory registered in a Scheduler. Its options are read by the scheduler while it runs, so
+// they are changed by SetJitter and SetOverlap.
+type ScheduledJob struct {
+	Name     string
+	Schedule ISchedule
+	Factory  IFlowFactory
+	Jitter   time.Duration
+	Overlap  OverlapPolicy
+
+	// mutex is the one of the scheduler
+	mutex   *sync.Mutex
+	running int
+	queued  []time.Time
+	history []*RunRecord
+}
+
+// SetJitter delays every run by a random duration up to jitter, so that the jobs due at the same time are spread
+func (j *ScheduledJob) SetJitter(jitter time.Duration) *ScheduledJob {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.Jitter = jitter
+	return j
+}
+
+func (j *ScheduledJob) SetOverlap(policy OverlapPolicy) *ScheduledJob {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.Overlap = policy
+	return j
+}
+
+// Scheduler runs flows on schedules from Start until Shutdown. A job added after Start is followed at once.
+type Scheduler struct {
+	clock        Clock
+	historyLimit int
+
+	mutex    sync.Mutex
+	jobs     []*ScheduledJob
+	names    map[string]*ScheduledJob
+	started  bool
+	stopping bool
+	stop     chan struct{}
+	loops    sync.WaitGroup
+	runs     sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		clock:        SystemClock,
+		historyLimit: 20,
+		names:        make(map[string]*ScheduledJob),
+		stop:         make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// SetClock sets the clock the schedules are followed with, e.g. a fake clock in tests
+func (s *Scheduler) SetClock(clock Clock) *Scheduler {
+	s.clock = clock
+	return s
+}
+
+// SetHistoryLimit sets how many runs are kept per job, 20 by default
+func (s *Scheduler) SetHistoryLimit(limit int) *Scheduler {
+	s.historyLimit = limit
+	return s
+}
+
+// Add registers a job, whose runs do not overlap by default. It fails with SchedulerShutdownError once Shutdown is
+// called.
+func (s *Scheduler) Add(name string, schedule ISchedule, factory IFlowFactory) (*ScheduledJob, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopping {
+		return nil, NewSchedulerShutdownError(name)
+	}
+	if _, ok := s.names[name]; ok {
+		return nil, NewDuplicateNameError("job", name)
+	}
+	job := &ScheduledJob{Name: name, Schedule: schedule, Factory: factory, mutex: &s.mutex}
+	s.jobs = append(s.jobs, job)
+	s.names[name] = job
+	if s.started {
+		s.loops.Add(1)
+		go s.loop(job)
+	}
+	return job, nil
+}
+
+// Every registers a job running at a fixed interval
+func (s *Scheduler) Every(name string, interval time.Duration, factory IFlowFactory) (*ScheduledJob, error) {
+	return s.Add(name, Every(interval), factory)
+}
+
+// Cron registers a job running at the times of a cron expression, see ParseCron
+func (s *Scheduler) Cron(name string, expr string, factory IFlowFactory) (*ScheduledJob, error) {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+	return s.Add(name, schedule, factory)
+}
+
+// Start starts following the schedules of the jobs added so far
+func (s *Scheduler) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.started || s.stopping {
+		return
+	}
+	s.started = true
+	for _, job := range s.jobs {
+		s.loops.Add(1)
+		go s.loop(job)
+	}
+}
+
+// Shutdown stops starting runs and drops the queued ones, then waits for the running flows. If ctx is done before
+// they finish, their context is cancelled, which fails them before their next node, and the error of ctx is returned
+// once they have returned.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	if !s.stopping {
+		s.stopping = true
+		close(s.stop)
+	}
+	for _, job := range s.jobs {
+		job.queued = nil
+	}
+	s.mutex.Unlock()
+	s.loops.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// History returns the recent runs of a job, the oldest first
+func (s *Scheduler) History(name string) []*RunRecord {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	job, ok := s.names[name]
+	if !ok {
+		return nil
+	}
+	return append([]*RunRecord(nil), job.history...)
+}
+
+func (s *Scheduler) loop(job *ScheduledJob) {
+	defer s.loops.Done()
+	next := job.Schedule.Next(s.clock.Now())
+	for !next.IsZero() {
+		delay := next.Sub(s.clock.Now())
+		s.mutex.Lock()
+		jitter := job.Jitter
+		s.mutex.Unlock()
+		if jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(jitter)))
+		}
+		timer := s.clock.NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-s.stop:
+			timer.Stop()
+			return
+		}
+		s.fire(job, next)
+
+		// The runs missed while the process was busy are not made up for
+		now := s.clock.Now()
+		if next = job.Schedule.Next(next); !next.IsZero() && next.Before(now) {
+			next = job.Schedule.Next(now)
+		}
+	}
+}
+
+func (s *Scheduler) fire(job *ScheduledJob, scheduled time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopping {
+		return
+	}
+	if job.running > 0 {
+		switch job.Overlap {
+		case OverlapSkip:
+			s.record(job, &RunRecord{Job: job.Name, Scheduled: scheduled, Skipped: true})
+			return
+		case OverlapQueue:
+			job.queued = append(job.queued, scheduled)
+			return
+		}
+	}
+	job.running++
+	s.runs.Add(1)
+	go s.run(job, scheduled)
+}
+
+// run runs the flow, and then the queued runs of the job one after the other
+func (s *Scheduler) run(job *ScheduledJob, scheduled time.Time) {
+	defer s.runs.Done()
+	for {
+		record := &RunRecord{Job: job.Name, Scheduled: scheduled, Started: s.clock.Now()}
+		record.Result = s.runFlow(job)
+		record.Finished = s.clock.Now()
+
+		s.mutex.Lock()
+		s.record(job, record)
+		if len(job.queued) == 0 {
+			job.running--
+			s.mutex.Unlock()
+			return
+		}
+		scheduled, job.queued = job.queued[0], job.queued[1:]
+		s.mutex.Unlock()
+	}
+}
+
+// runFlow builds and runs a flow with the context of the scheduler. A panic is turned into a failure with
+// PanicHappened.
+func (s *Scheduler) runFlow(job *ScheduledJob) (result *_Result) {
+	defer func() {
+		if a := recover(); a != nil {
+			result = &_Result{Err: NewPanicHappened(string(debug.Stack()))}
+		}
+	}()
+	flow := job.Factory()
+	flow.setContext(s.ctx)
+	SetContext(flow.getData(), s.ctx)
+	return flow.Wait()
+}
+
+// record must be called with the mutex held
+func (s *Scheduler) record(job *ScheduledJob, record *RunRecord) {
+	job.history = append(job.history, record)
+	if s.historyLimit > 0 && len(job.history) > s.historyLimit {
+		job.history = job.history[len(job.history)-s.historyLimit:]
+	}
+}
@@ -0,0 +1,202 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2021, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		expr  string
+		after time.Time
+		next  time.Time
+	}{
+		{"*/15 9-17 * * 1-5", at(4, 8, 59), at(4, 9, 0)},
+		{"*/15 9-17 * * 1-5", at(4, 17, 50), at(5, 9, 0)},
+		{"0 0 13 * 5", at(1, 0, 0), at(8, 0, 0)},
+		{"0 0 * * 7", at(1, 0, 0), at(3, 0, 0)},
+		{"30 12 1,15 * *", at(1, 12, 30), at(15, 12, 30)},
+		{"@daily", at(1, 10, 0), at(2, 0, 0)},
+		{"@hourly", at(1, 10, 0), at(1, 11, 0)},
+	}
+	for _, c := range cases {
+		schedule, err := ParseCron(c.expr)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", c.expr, err)
+			continue
+		}
+		if next := schedule.Next(c.after); !next.Equal(c.next) {
+			t.Errorf("expected %q after %s to be %s, got %s", c.expr, c.after, c.next, next)
+		}
+	}
+
+	for _, expr := range []string{"* * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *", "* * 0 * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected %q to be refused", expr)
+		} else if _, ok := err.(*CronSyntaxError); !ok {
+			t.Errorf("expected CronSyntaxError for %q, got %v", expr, err)
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	if next := Every(time.Hour).Next(now); !next.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected the next run an hour later, got %s", next)
+	}
+	if next := Every(0).Next(now); !next.IsZero() {
+		t.Errorf("expected no run without interval, got %s", next)
+	}
+}
+
+// waitForHistory waits until the job has count runs in its history
+func waitForHistory(t *testing.T, scheduler *Scheduler, name string, count int) []*RunRecord {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		history := scheduler.History(name)
+		if len(history) >= count {
+			return history
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d runs of %s, got %d", count, name, len(history))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerRunsJobs(t *testing.T) {
+	clock := newTestClock()
+	start := clock.Now()
+	scheduler := NewScheduler().SetClock(clock)
+	if _, err := scheduler.Every("job", time.Minute, func() IFlowEngine {
+		return NewFlow().Do(succeed)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scheduler.Every("job", time.Hour, nil); err == nil {
+		t.Error("expected the duplicate job to be refused")
+	}
+	if _, err := scheduler.Cron("broken", "* *", nil); err == nil {
+		t.Error("expected the broken cron expression to be refused")
+	}
+
+	scheduler.Start()
+	for run := 1; run <= 2; run++ {
+		clock.waitForTimers(t, 1)
+		clock.advance(time.Minute)
+		waitForHistory(t, scheduler, "job", run)
+	}
+	if err := scheduler.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	history := scheduler.History("job")
+	for index, record := range history {
+		if record.Skipped || record.Result == nil || record.Result.Err != nil {
+			t.Errorf("expected run %d to succeed, got %+v", index, record)
+		}
+		if scheduled := start.Add(time.Duration(index+1) * time.Minute); !record.Scheduled.Equal(scheduled) {
+			t.Errorf("expected run %d to be scheduled at %s, got %s", index, scheduled, record.Scheduled)
+		}
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	clock := newTestClock()
+	scheduler := NewScheduler().SetClock(clock)
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	if _, err := scheduler.Every("job", time.Minute, func() IFlowEngine {
+		return NewFlow().Do(func(_data *_Data) *_Result {
+			started <- struct{}{}
+			<-release
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduler.Start()
+	clock.waitForTimers(t, 1)
+	clock.advance(time.Minute)
+	<-started
+	clock.waitForTimers(t, 1)
+	clock.advance(time.Minute)
+	history := waitForHistory(t, scheduler, "job", 1)
+	if !history[0].Skipped {
+		t.Errorf("expected the overlapping run to be skipped, got %+v", history[0])
+	}
+	close(release)
+	if err := scheduler.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if history := scheduler.History("job"); len(history) != 2 || history[1].Skipped {
+		t.Errorf("expected the first run to complete, got %d runs", len(history))
+	}
+}
+
+func TestSchedulerShutdownCancelsRunningFlows(t *testing.T) {
+	clock := newTestClock()
+	scheduler := NewScheduler().SetClock(clock)
+	started := make(chan struct{})
+	if _, err := scheduler.Every("job", time.Minute, func() IFlowEngine {
+		return NewFlow().Do(func(_data *_Data) *_Result {
+			close(started)
+			<-GetContext(_data).Done()
+			return nil
+		}).Do(mark("skipped"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduler.Start()
+	clock.waitForTimers(t, 1)
+	clock.advance(time.Minute)
+	<-started
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := scheduler.Shutdown(ctx); err != context.Canceled {
+		t.Errorf("expected the shutdown to be cut short, got %v", err)
+	}
+	if history := scheduler.History("job"); len(history) != 1 || history[0].Result.Err != context.Canceled {
+		t.Errorf("expected the running flow to be cancelled, got %d runs", len(history))
+	}
+}
+
+func TestSchedulerFollowsJobsAddedAfterStart(t *testing.T) {
+	clock := newTestClock()
+	scheduler := NewScheduler().SetClock(clock)
+	scheduler.Start()
+	job, err := scheduler.Every("late", time.Minute, func() IFlowEngine {
+		return NewFlow().Do(succeed)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.SetJitter(time.Nanosecond)
+	clock.waitForTimers(t, 1)
+	clock.advance(time.Minute)
+	waitForHistory(t, scheduler, "late", 1)
+	if err := scheduler.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchedulerRefusesJobsAfterShutdown(t *testing.T) {
+	scheduler := NewScheduler()
+	if err := scheduler.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_, err := scheduler.Every("late", time.Minute, func() IFlowEngine {
+		return NewFlow().Do(succeed)
+	})
+	if shutdown, ok := err.(*SchedulerShutdownError); !ok || shutdown.Job != "late" {
+		t.Errorf("expected SchedulerShutdownError, got %v", err)
+	}
+	if scheduler.History("late") != nil {
+		t.Error("expected the refused job not to be registered")
+	}
+}
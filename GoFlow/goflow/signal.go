@@ -0,0 +1,92 @@
+package goflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type SignalTimeoutError struct {
+	Name    string
+	Timeout time.Duration
+}
+
+func NewSignalTimeoutError(name string, timeout time.Duration) *SignalTimeoutError {
+	return &SignalTimeoutError{Name: name, Timeout: timeout}
+}
+
+func (s *SignalTimeoutError) Error() string {
+	return fmt.Sprintf("signal %s did not arrive within %s", s.Name, s.Timeout)
+}
+
+// SignalUnavailableError fails a WaitForSignal node of a flow which was not run by Start or StartResume, since there
+// is no Handle to send the signal to
+type SignalUnavailableError struct {
+	Name string
+}
+
+func NewSignalUnavailableError(name string) *SignalUnavailableError {
+	return &SignalUnavailableError{Name: name}
+}
+
+func (s *SignalUnavailableError) Error() string {
+	return fmt.Sprintf("signal %s can not be received by a flow which was not started", s.Name)
+}
+
+type handleKey struct{}
+
+func handleOf(ctx context.Context) *Handle {
+	if ctx == nil {
+		return nil
+	}
+	handle, _ := ctx.Value(handleKey{}).(*Handle)
+	return handle
+}
+
+// Signal sends a signal to the flow. The payload is kept until a WaitForSignal node of the same name takes it, so a
+// signal sent before the node is reached is not lost. Each payload is taken by one node only.
+func (h *Handle) Signal(name string, payload interface{}) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.signals[name] = append(h.signals[name], payload)
+	close(h.arrived)
+	h.arrived = make(chan struct{})
+}
+
+// receive waits for a signal until the timeout, 0 meaning no timeout, or until the context is done
+func (h *Handle) receive(ctx context.Context, name string, timeout time.Duration, clock Clock) (interface{}, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := clock.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C()
+	}
+	for {
+		h.mutex.Lock()
+		if payloads := h.signals[name]; len(payloads) != 0 {
+			h.signals[name] = payloads[1:]
+			h.mutex.Unlock()
+			return payloads[0], nil
+		}
+		arrived := h.arrived
+		h.mutex.Unlock()
+
+		select {
+		case <-arrived:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-expired:
+			return nil, NewSignalTimeoutError(name, timeout)
+		}
+	}
+}
+
+// suspend saves the checkpoint of a flow about to wait for a signal, with the signal in Waiting, so that the flow can
+// be found and resumed by StartResume once the process has restarted
+func (c *checkpointer) suspend(nodes []IBasicFlowNode, index int, data *_Data, result **_Result) {
+	node, ok := nodes[index].(*SignalNode)
+	if !c.enabled() || !ok || node.GetShouldSkip() || (*result).Err != nil || (*result).StatusCode != 0 {
+		return
+	}
+	c.write(nodes, index-1, node.Name, data, result)
+}
@@ -0,0 +1,84 @@
+package goflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func applyPayload(_data *_Data, payload interface{}) *_Result {
+	_data.FunctionName += payload.(string)
+	return nil
+}
+
+func TestSignalSentBeforeTheNodeIsKept(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	flow := NewFlow().Do(func(_data *_Data) *_Result {
+		close(started)
+		<-release
+		return mark("first")(_data)
+	}).WaitForSignal("approve", 0).SetSignalApply(applyPayload).Do(mark("last"))
+
+	handle := flow.Start(context.Background())
+	<-started
+	handle.Signal("approve", "approved;")
+	close(release)
+	<-handle.Done()
+	if result := handle.Result(); result.Err != nil {
+		t.Fatalf("unexpected failure %v", result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "first;approved;last;" {
+		t.Errorf("expected the payload to be applied between the nodes, got %q", data)
+	}
+}
+
+func TestSignalTimesOut(t *testing.T) {
+	clock := newTestClock()
+	flow := NewFlow().SetClock(clock).WaitForSignal("approve", time.Minute).Do(mark("skipped"))
+	handle := flow.Start(context.Background())
+	clock.waitForTimers(t, 1)
+	clock.advance(time.Minute)
+	<-handle.Done()
+	if timeout, ok := handle.Result().Err.(*SignalTimeoutError); !ok || timeout.Name != "approve" {
+		t.Errorf("expected SignalTimeoutError, got %v", handle.Result().Err)
+	}
+	if flow.getData().FunctionName != "" {
+		t.Errorf("expected the nodes after the signal to be skipped, got %q", flow.getData().FunctionName)
+	}
+}
+
+func TestSignalNeedsAStartedFlow(t *testing.T) {
+	result := NewFlow().WaitForSignal("approve", 0).Wait()
+	if _, ok := result.Err.(*SignalUnavailableError); !ok {
+		t.Errorf("expected SignalUnavailableError, got %v", result.Err)
+	}
+}
+
+func TestSignalSuspendsTheCheckpoint(t *testing.T) {
+	store := NewMemoryCheckpointStore()
+	flow := NewFlow().SetCheckpointStore(store).Do(mark("first")).
+		WaitForSignal("approve", 0).SetSignalApply(applyPayload).Do(mark("last"))
+	handle := flow.StartResume(context.Background(), "order-1")
+
+	deadline := time.Now().Add(5 * time.Second)
+	checkpoint, err := store.Load("order-1")
+	for err == nil && checkpoint == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		checkpoint, err = store.Load("order-1")
+	}
+	if err != nil || checkpoint == nil || checkpoint.Waiting != "approve" || checkpoint.NodeIndex != 0 {
+		t.Fatalf("expected the flow to be saved as waiting for the signal, got %+v, %v", checkpoint, err)
+	}
+
+	handle.Signal("approve", "approved;")
+	<-handle.Done()
+	if result := handle.Result(); result.Err != nil {
+		t.Fatalf("unexpected failure %v", result.Err)
+	}
+	if data := flow.getData().FunctionName; data != "first;approved;last;" {
+		t.Errorf("unexpected data %q", data)
+	}
+	if checkpoint, _ := store.Load("order-1"); checkpoint != nil {
+		t.Errorf("expected the checkpoint to be removed, got %+v", checkpoint)
+	}
+}
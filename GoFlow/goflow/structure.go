@@ -26,4 +26,14 @@ func InitStructure(data *_Data){
 	//Initialize your data here if needed
 }
 
+func SetContext(data *_Data, ctx context.Context){
+	//Keep the context given to Start in your data here if needed
+	data.Ctx = ctx
+}
+
+func GetContext(data *_Data) context.Context{
+	//Return the context kept by SetContext, nil is taken as context.Background()
+	return data.Ctx
+}
+
 //************************DEFINE YOUR STRUCTURE ABOVE****************************//